@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdApply)
+}
+
+var cmdApply = &cobra.Command{
+	Use:   "apply -f [manifest]",
+	Short: "Create every resource described in a stack manifest",
+	Long: `Apply reads a YAML or JSON stack manifest describing a role, its policies, a function
+				and a gateway, and creates them in dependency order. The role ARN is passed to the
+				function and the function to the gateway automatically.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		stack, err := helper.LoadStack(StackPath)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if err := prov.CreateAllResources(cmd.Context(), stack); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Stack applied: ", StackPath)
+	},
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdConcurrency)
+}
+
+var cmdConcurrency = &cobra.Command{
+	Use:   "concurrency [flags]",
+	Short: "View and change the concurrency of a Lambda function",
+	Long: `Concurrency prints the reserved concurrency of the function and the provisioned concurrency
+			of its aliases. Use --reserved to reserve and cap its concurrent executions, or --unreserve to
+			remove the reservation, and --alias with --provisioned to keep environments initialised.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := LambdaArgs.FunctionName
+		if cmd.Flags().Changed("reserved") || Unreserve {
+			var n *int64
+			if !Unreserve {
+				n = aws.Int64(ReservedConcurrency)
+			}
+			if err := prov.SetReservedConcurrency(cmd.Context(), name, n); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+		if cmd.Flags().Changed("provisioned") {
+			if Alias == "" {
+				fmt.Println("Supply --alias to provision concurrency for")
+				os.Exit(1)
+			}
+			if err := prov.SetProvisionedConcurrency(cmd.Context(), name, Alias, ProvisionedConcurrency); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		c, err := prov.FunctionConcurrency(cmd.Context(), name)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if c.Reserved == nil {
+			fmt.Println("reserved: none, uses the account's unreserved concurrency")
+		} else {
+			fmt.Printf("reserved: %d\n", aws.Int64Value(c.Reserved))
+		}
+		for _, pc := range c.Provisioned {
+			fmt.Printf("provisioned %s: %d of %d allocated, %s\n", aws.StringValue(pc.FunctionArn),
+				aws.Int64Value(pc.AllocatedProvisionedConcurrentExecutions),
+				aws.Int64Value(pc.RequestedProvisionedConcurrentExecutions), aws.StringValue(pc.Status))
+		}
+	},
+}
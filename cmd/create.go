@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +14,9 @@ func init() {
 	cmdCreate.AddCommand(cmdAttachPolicy)
 	cmdCreate.AddCommand(cmdCreateLambda)
 	cmdCreate.AddCommand(cmdCreateGateway)
+	cmdCreate.AddCommand(cmdCreateLayer)
+	cmdCreate.AddCommand(cmdCreateTrigger)
+	cmdCreate.AddCommand(cmdCreateSchedule)
 }
 
 var cmdCreate = &cobra.Command{
@@ -34,12 +37,12 @@ var cmdCreateRole = &cobra.Command{
 	Long:  "This command creates an AWS IAM Role, which can be used to attach policies and for deploying other services",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		role, err := helper.CreateRole(RoleArgs, sess)
+		role, err := prov.CreateRole(cmd.Context(), RoleArgs)
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("Role created: ", role)
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		fmt.Println("Role created: ", role)
 	},
 }
 
@@ -51,12 +54,12 @@ var cmdAttachPolicy = &cobra.Command{
 				to perform certain functions.`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		res, err := helper.AttachPolicy(AttachPolArgs, sess)
+		res, err := prov.AttachPolicy(cmd.Context(), AttachPolArgs)
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("Attached policy to role: ", res)
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		fmt.Println("Attached policy to role: ", res)
 	},
 }
 
@@ -67,12 +70,12 @@ var cmdCreateLambda = &cobra.Command{
 	with the given arguments which configures the function`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		lmb, err := helper.CreateLambda(LambdaArgs, sess)
+		lmb, err := prov.CreateLambda(cmd.Context(), lambdaArgs(cmd))
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("Function created: ", lmb)
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		fmt.Println("Function created: ", lmb)
 	},
 }
 
@@ -83,12 +86,69 @@ var cmdCreateGateway = &cobra.Command{
 	or to trigger our workloads through a Lambda, via HTTPS.`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		gwy, id, err := helper.CreateGateway(GatewayArgs, sess)
+		_, err := prov.SetupGateway(cmd.Context(), GatewayArgs)
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("API Gateway created: ", gwy)
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
-		helper.ConfigureAPIEndpoint(id, gwy.Id, gwy.Name, LambdaArgs.FunctionName, sess)
+	},
+}
+
+var cmdCreateLayer = &cobra.Command{
+	Use:   "layer [args]",
+	Short: "Publish a Lambda layer version",
+	Long: `This subcommand zips the files of the source directory, such as shared helpers or CA bundles,
+	and publishes them as a new version of the layer, which Lambda extracts to /opt for each function
+	using it. Functions attach the layer with --layer and the layer name.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := prov.PublishLayer(cmd.Context(), LayerArgs)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Layer version published: ", aws.StringValue(res.LayerVersionArn))
+	},
+}
+
+var cmdCreateTrigger = &cobra.Command{
+	Use:   "trigger [args]",
+	Short: "Trigger a Lambda function from a queue or stream",
+	Long: `This subcommand creates an event source mapping, which has Lambda read batches of messages
+	from an SQS queue, or records from a Kinesis or DynamoDB stream, and invoke the function with them.
+	The execution policy allowing the function's role to read from the source is attached to it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := prov.CreateTrigger(cmd.Context(), TriggerArgs)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Trigger created: ", aws.StringValue(m.UUID), aws.StringValue(m.State))
+	},
+}
+
+var cmdCreateSchedule = &cobra.Command{
+	Use:   "schedule [args]",
+	Short: "Invoke a Lambda function on a schedule",
+	Long: `This subcommand creates a CloudWatch Events rule invoking the function on a cron or rate
+	schedule, such as nightly drift checks, and allows the rule to invoke the function. Cron expressions
+	take the six fields of Events, minutes to year, as in "0 2 * * ? *" for 2am UTC every day.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		payload, err := readArg(ScheduleArgs.Payload)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		s := ScheduleArgs
+		s.Payload = payload
+
+		rule, err := prov.CreateSchedule(cmd.Context(), s)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Schedule created: ", rule)
 	},
 }
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +15,9 @@ func init() {
 	cmdDelete.AddCommand(cmdDeleteRole)
 	cmdDelete.AddCommand(cmdDeleteLambda)
 	cmdDelete.AddCommand(cmdDeleteGateway)
+	cmdDelete.AddCommand(cmdDeleteLayer)
+	cmdDelete.AddCommand(cmdDeleteTrigger)
+	cmdDelete.AddCommand(cmdDeleteSchedule)
 }
 
 var cmdDelete = &cobra.Command{
@@ -34,12 +40,12 @@ var cmdDeleteRole = &cobra.Command{
 				tear everything down.`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		role, err := helper.DeleteRole(RoleArgs.RoleName, sess)
+		role, err := prov.DeleteRole(cmd.Context(), RoleArgs.RoleName)
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("Deleted role: ", role)
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		fmt.Println("Deleted role: ", role)
 	},
 }
 
@@ -47,15 +53,21 @@ var cmdDeleteLambda = &cobra.Command{
 	Use:   "lambda",
 	Short: "Delete a Lambda function",
 	Long: `This subcommand will delete a given Lambda resource from your AWS envrionment,
-				supply the name of the function.`,
+				supply the name of the function. The schedules created for the function are deleted with
+				it. A function attached to a VPC is only deleted once its network interfaces are released,
+				which can take many minutes and is bounded by --eni-timeout.`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		lmb, err := helper.DeleteLambda(LambdaArgs.FunctionName, sess)
+		lmb, err := prov.DeleteLambda(cmd.Context(), LambdaArgs.FunctionName, DeleteSecurityGroups)
+		if errors.Is(err, helper.ErrFunctionNotFound) {
+			fmt.Println("Function already gone: ", LambdaArgs.FunctionName)
+			return
+		}
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("Function deleted: ", lmb)
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		fmt.Println("Function deleted: ", lmb)
 	},
 }
 
@@ -63,14 +75,73 @@ var cmdDeleteGateway = &cobra.Command{
 	Use:   "gateway [flags]",
 	Short: "Gateway subcommand deletes an API Gateway service",
 	Long: `The Gateway subcommand deletes an API Gateway of the given name from your AWS
-			environment, supply the API Gateway name.`,
+			environment, supply the API Gateway name or, when several share the name, its ID.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := resolveGateway(cmd)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		gwy, err := prov.DeleteRestAPI(cmd.Context(), id)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Gateway (REST API) deleted: ", gwy)
+	},
+}
+
+var cmdDeleteLayer = &cobra.Command{
+	Use:   "layer [flags]",
+	Short: "Prune the versions of a Lambda layer",
+	Long: `This subcommand deletes every version of the layer of the given name but the newest --keep,
+			--keep 0 deletes them all. Functions already using a deleted version keep it, but it can no
+			longer be attached to another function.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		deleted, err := prov.PruneLayer(cmd.Context(), LayerArgs.Name, KeepVersions)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Layer versions deleted: ", deleted)
+	},
+}
+
+var cmdDeleteTrigger = &cobra.Command{
+	Use:   "trigger [flags]",
+	Short: "Delete a trigger of a Lambda function",
+	Long: `This subcommand deletes the event source mapping with the given UUID, as listed by describe
+			trigger. The execution policy creating it attached to the function's role is detached again,
+			unless another trigger of the function reads from the same service.`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		gwy, err := helper.DeleteRestAPI(GatewayArgs.Name, sess)
+		m, err := prov.DeleteTrigger(cmd.Context(), TriggerUUID)
 		if err != nil {
-			fmt.Printf(err.Error())
-		} else {
-			fmt.Println("Gateway (REST API) deleted: ", gwy)
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Trigger deleted: ", aws.StringValue(m.UUID), aws.StringValue(m.EventSourceArn))
+	},
+}
+
+var cmdDeleteSchedule = &cobra.Command{
+	Use:   "schedule [flags]",
+	Short: "Delete the schedule of a Lambda function",
+	Long: `This subcommand deletes the CloudWatch Events rule of the given name, or the default rule of the
+			given function, along with its target and the permission allowing it to invoke the function.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if ScheduleArgs.Name == "" && ScheduleArgs.FunctionName == "" {
+			fmt.Println("supply the schedule --name or --function")
+			os.Exit(1)
+		}
+		rule := ScheduleArgs.RuleName()
+		if err := prov.DeleteSchedule(cmd.Context(), rule); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		fmt.Println("Schedule deleted: ", rule)
 	},
 }
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdDeploy)
+	cmdDeploy.AddCommand(cmdDeployLambda)
+}
+
+var cmdDeploy = &cobra.Command{
+	Use:   "deploy [resource to deploy]",
+	Short: "Deploy gradually rolls out a new version of a resource",
+	Long:  "Use this command to roll out a change to a resource gradually, the resource is specified in a subcommand",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Supply a subcommand to deploy a resource")
+	},
+}
+
+var cmdDeployLambda = &cobra.Command{
+	Use:   "lambda [flags]",
+	Short: "Deploy a new version of a Lambda function behind a canary",
+	Long: `This subcommand publishes a new version of the function with the code and settings given,
+			then routes the --canary share of the alias' traffic to it for --interval while watching the
+			version's errors. With no errors the alias is promoted to the new version, otherwise it is
+			rolled back. Create the gateway with --alias so its traffic follows the alias.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		weight, err := parsePercent(CanaryWeight)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		lmb, err := prov.DeployLambda(cmd.Context(), LambdaArgs, helper.Canary{
+			Alias:    Alias,
+			Weight:   weight,
+			Interval: CanaryInterval,
+		})
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Alias %s points at version %s\n", Alias, aws.StringValue(lmb.Version))
+	},
+}
+
+// parsePercent reads a percentage such as "10%" or "10" as a fraction
+func parsePercent(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || pct <= 0 || pct >= 100 {
+		return 0, fmt.Errorf("canary must be a percentage between 0 and 100, got %q", s)
+	}
+	return pct / 100, nil
+}
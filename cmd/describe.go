@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdDescribe)
+	cmdDescribe.AddCommand(cmdDescribeLambda)
+	cmdDescribe.AddCommand(cmdDescribeGateway)
+	cmdDescribe.AddCommand(cmdDescribeLayer)
+	cmdDescribe.AddCommand(cmdDescribeTrigger)
+}
+
+var cmdDescribe = &cobra.Command{
+	Use:   "describe [resource]",
+	Short: "Describe an existing AWS resource",
+	Long:  "Use this command to show how an existing resource is configured, the resource is specified in a subcommand",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Supply a subcommand to describe a resource")
+	},
+}
+
+var cmdDescribeLambda = &cobra.Command{
+	Use:   "lambda [flags]",
+	Short: "Describe a Lambda function",
+	Long: `This subcommand shows the configuration of the function of the given name, and how it
+			handles asynchronous invocations; the retries, the maximum event age and the destinations.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		d, err := prov.DescribeLambda(cmd.Context(), LambdaArgs.FunctionName)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		fn := d.Configuration
+		fmt.Printf("function %s\n  arn: %s\n  runtime: %s\n  handler: %s\n  role: %s\n  memory: %dMB\n  timeout: %ds\n  state: %s\n",
+			aws.StringValue(fn.FunctionName), aws.StringValue(fn.FunctionArn), aws.StringValue(fn.Runtime), aws.StringValue(fn.Handler),
+			aws.StringValue(fn.Role), aws.Int64Value(fn.MemorySize), aws.Int64Value(fn.Timeout), aws.StringValue(fn.State))
+
+		cfg := d.InvokeConfig
+		if cfg == nil {
+			fmt.Println("  asynchronous invocation: defaults, 2 retries over up to 6 hours and no destinations")
+			return
+		}
+		fmt.Println("  asynchronous invocation:")
+		if cfg.MaximumRetryAttempts != nil {
+			fmt.Printf("    retries: %d\n", aws.Int64Value(cfg.MaximumRetryAttempts))
+		}
+		if cfg.MaximumEventAgeInSeconds != nil {
+			fmt.Printf("    maximum event age: %ds\n", aws.Int64Value(cfg.MaximumEventAgeInSeconds))
+		}
+		if dest := cfg.DestinationConfig; dest != nil {
+			if dest.OnSuccess != nil && dest.OnSuccess.Destination != nil {
+				fmt.Printf("    on success: %s\n", aws.StringValue(dest.OnSuccess.Destination))
+			}
+			if dest.OnFailure != nil && dest.OnFailure.Destination != nil {
+				fmt.Printf("    on failure: %s\n", aws.StringValue(dest.OnFailure.Destination))
+			}
+		}
+	},
+}
+
+var cmdDescribeGateway = &cobra.Command{
+	Use:   "gateway [flags]",
+	Short: "Describe an API Gateway REST API",
+	Long: `This subcommand shows the REST API of the given name or ID along with its resources
+			and stages.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := resolveGateway(cmd)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		d, err := prov.DescribeGateway(cmd.Context(), id)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("gateway %s\n  id: %s\n  description: %s\n  created: %s\n",
+			aws.StringValue(d.API.Name), aws.StringValue(d.API.Id), aws.StringValue(d.API.Description), aws.TimeValue(d.API.CreatedDate))
+		for _, r := range d.Resources {
+			fmt.Printf("  resource %s %s\n", aws.StringValue(r.Id), aws.StringValue(r.Path))
+		}
+		for _, s := range d.Stages {
+			fmt.Printf("  stage %s deployment %s\n", aws.StringValue(s.StageName), aws.StringValue(s.DeploymentId))
+		}
+	},
+}
+
+var cmdDescribeLayer = &cobra.Command{
+	Use:   "layer [flags]",
+	Short: "List the versions of a Lambda layer",
+	Long: `This subcommand lists the versions of the layer of the given name, newest first, with the
+			runtimes each is compatible with.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		versions, err := prov.LayerVersions(cmd.Context(), LayerArgs.Name)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if len(versions) == 0 {
+			fmt.Printf("layer %s has no versions\n", LayerArgs.Name)
+			return
+		}
+
+		fmt.Printf("layer %s\n", LayerArgs.Name)
+		for _, v := range versions {
+			fmt.Printf("  version %d %s\n    created: %s\n    runtimes: %s\n", aws.Int64Value(v.Version), aws.StringValue(v.LayerVersionArn),
+				aws.StringValue(v.CreatedDate), strings.Join(aws.StringValueSlice(v.CompatibleRuntimes), ", "))
+		}
+	},
+}
+
+var cmdDescribeTrigger = &cobra.Command{
+	Use:   "trigger [flags]",
+	Short: "List the triggers of a Lambda function",
+	Long: `This subcommand lists the event source mappings invoking the function of the given name, with
+			the UUID delete trigger takes, the source and the state of each.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mappings, err := prov.Triggers(cmd.Context(), TriggerArgs.FunctionName)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if len(mappings) == 0 {
+			fmt.Printf("function %s has no triggers\n", TriggerArgs.FunctionName)
+			return
+		}
+
+		fmt.Printf("function %s\n", TriggerArgs.FunctionName)
+		for _, m := range mappings {
+			fmt.Printf("  trigger %s\n    source: %s\n    batch size: %d\n    state: %s\n", aws.StringValue(m.UUID),
+				aws.StringValue(m.EventSourceArn), aws.Int64Value(m.BatchSize), aws.StringValue(m.State))
+		}
+	},
+}
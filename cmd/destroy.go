@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdDestroy)
+}
+
+var cmdDestroy = &cobra.Command{
+	Use:   "destroy -f [manifest]",
+	Short: "Delete every resource described in a stack manifest",
+	Long: `Destroy reads the same stack manifest as apply and tears it down in reverse dependency
+				order; the gateway, the function's schedules and triggers, its gateway permissions, the
+				function, the policies attached to the role and the role. Each resource is reported as deleted or already gone.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		stack, err := helper.LoadStack(StackPath)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		results, err := prov.DeleteAllResources(cmd.Context(), stack)
+		for _, r := range results {
+			fmt.Println(r)
+		}
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdInvoke)
+}
+
+var cmdInvoke = &cobra.Command{
+	Use:   "invoke [flags]",
+	Short: "Invoke a Lambda function directly",
+	Long: `Invoke calls the function with the payload, prefix it with @ to read it from a file, and prints
+			the tail of the execution log, any function error and the response. Use --proxy to send the
+			payload as the body of the request an API Gateway proxy integration would send, and --async
+			to queue the invocation rather than wait for it. It exits non-zero when the function fails.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		payload, err := readArg(Payload)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		event := []byte(payload)
+		if Proxy {
+			path := ProxyPath
+			if path == "" {
+				path = "/" + LambdaArgs.FunctionName
+			}
+			if event, err = prov.ProxyRequest(path, payload); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		name := LambdaArgs.FunctionName
+		if Alias != "" {
+			name += ":" + Alias
+		}
+		res, err := prov.Invoke(cmd.Context(), name, event, Async)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("status: %d\n", res.StatusCode)
+		if res.ExecutedVersion != "" {
+			fmt.Printf("version: %s\n", res.ExecutedVersion)
+		}
+		if res.Log != "" {
+			fmt.Println("log:")
+			fmt.Println(res.Log)
+		}
+		if res.FunctionError != "" {
+			fmt.Printf("function error: %s\n", res.FunctionError)
+		}
+		if len(res.Payload) > 0 {
+			fmt.Printf("response: %s\n", res.Payload)
+		}
+
+		if !res.OK() {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdLogs)
+}
+
+var cmdLogs = &cobra.Command{
+	Use:   "logs [flags]",
+	Short: "Print the CloudWatch logs of a Lambda function",
+	Long: `Logs prints the events the function logged in the last --since, across all of its log
+			streams in time order. Use --filter to only print events matching a CloudWatch Logs filter
+			pattern, such as ERROR, and --follow to keep printing new events as they are logged.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := prov.FunctionLogs(cmd.Context(), LambdaArgs.FunctionName, time.Now().Add(-Since), LogFilter, Follow,
+			func(e *cloudwatchlogs.FilteredLogEvent) {
+				fmt.Printf("%s %s %s\n", aws.MillisecondsTimeValue(e.Timestamp).Format(time.RFC3339Nano),
+					aws.StringValue(e.LogStreamName), strings.TrimRight(aws.StringValue(e.Message), "\n"))
+			})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdPlan)
+}
+
+var cmdPlan = &cobra.Command{
+	Use:   "plan -f [manifest]",
+	Short: "Show the calls apply or destroy would make for a stack manifest",
+	Long: `Plan runs apply, or destroy with --destroy, against the stack manifest without changing
+				anything. Reads are made as normal but every IAM, Lambda and API Gateway call which would
+				create, change or delete something is printed with its exact parameters instead of being sent.
+				Resources the plan would create are shown with placeholder IDs.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		stack, err := helper.LoadStack(StackPath)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if PlanDestroy {
+			_, err = prov.DeleteAllResources(cmd.Context(), stack)
+		} else {
+			err = prov.CreateAllResources(cmd.Context(), stack)
+		}
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	},
+}
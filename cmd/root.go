@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/VariableExp0rt/lambda-and-fun/config/helper"
 	"github.com/VariableExp0rt/lambda-and-fun/config/session"
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
 	awssess "github.com/aws/aws-sdk-go/aws/session"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +25,9 @@ var (
 	// Region is exported to use in helper package
 	Region string
 	sess   *awssess.Session
+	prov   *helper.Provisioner
+	// plan collects the calls a dry run would have made, it is only set for plan and --dry-run
+	plan *helper.Plan
 	// AttachPolArgs is exported to use in helper package
 	AttachPolArgs helper.AttachPolicyInput
 	// RoleArgs is exported to use in helper package
@@ -28,6 +36,66 @@ var (
 	LambdaArgs helper.Lambda
 	// GatewayArgs is exported to use in helper package
 	GatewayArgs helper.Gateway
+	// LayerArgs is the layer create layer publishes, and the layer describe and delete layer act on
+	LayerArgs helper.Layer
+	// KeepVersions is how many of the newest versions of a layer delete layer keeps
+	KeepVersions int
+	// TriggerArgs is the event source mapping create trigger creates, and whose function describe trigger lists
+	TriggerArgs helper.Trigger
+	// TriggerUUID is the event source mapping delete trigger deletes
+	TriggerUUID string
+	// ScheduleArgs is the schedule create schedule creates and delete schedule deletes, its Payload may be
+	// @file to read it from a file
+	ScheduleArgs helper.Schedule
+	// StackPath is the stack manifest read by apply and destroy
+	StackPath string
+	// Timeout is how long each operation waits for its resource to be ready
+	Timeout time.Duration
+	// ENITimeout is how long delete lambda waits for the network interfaces of a VPC function to be released
+	ENITimeout time.Duration
+	// StatePath is the file recording the identifiers of created resources
+	StatePath string
+	// OnConflict is what creates do when the resource already exists
+	OnConflict string
+	// Publish makes update lambda publish a new version
+	Publish bool
+	// Alias is the alias update lambda points at the published version
+	Alias string
+	// TestBody is the request body test gateway posts, or @file to read it from a file
+	TestBody string
+	// PlanDestroy makes plan show what destroy would do rather than apply
+	PlanDestroy bool
+	// Bucket is the S3 bucket deployment packages are staged in
+	Bucket string
+	// S3Endpoint replaces the S3 endpoint, to stage packages in a local S3-compatible stand-in
+	S3Endpoint string
+	// DeleteSecurityGroups deletes the security groups of a VPC function along with it
+	DeleteSecurityGroups bool
+	// CanaryWeight is the percentage of an alias' traffic deploy lambda routes to the new version
+	CanaryWeight string
+	// CanaryInterval is how long deploy lambda watches the new version before promoting it
+	CanaryInterval time.Duration
+	// Payload is the event invoke sends, or @file to read it from a file
+	Payload string
+	// Async queues the invocation rather than waiting for the response
+	Async bool
+	// Proxy wraps the payload in the event an API Gateway proxy integration sends, ProxyPath is its path
+	Proxy     bool
+	ProxyPath string
+	// Since is how far back logs starts reading, LogFilter the filter pattern events must match and Follow
+	// keeps polling for new events
+	Since     time.Duration
+	LogFilter string
+	Follow    bool
+	// ReservedConcurrency is bound to the reserved concurrency flags, which only apply when given as 0 is a
+	// valid reservation. Unreserve removes the reservation and ProvisionedConcurrency is set on an alias
+	ReservedConcurrency    int64
+	Unreserve              bool
+	ProvisionedConcurrency int64
+	// MaxRetries is bound to --max-retries, which only applies when given as 0 retries is a valid setting
+	MaxRetries int64
+	// DryRun prints the calls a create or delete would make instead of making them
+	DryRun bool
 )
 
 var rootCmd = &cobra.Command{
@@ -36,11 +104,100 @@ var rootCmd = &cobra.Command{
 	Long: `My App is a binary which allows you to build a very quick
 				Lambda-Over-HTTPS function to be able to trigger CloudFormation templates with
 				as simple cURL command.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		sess = session.NewSession(Region)
+		if S3Endpoint != "" {
+			session.UseS3Endpoint(sess, S3Endpoint)
+		}
+		if DryRun || cmd == cmdPlan {
+			plan = &helper.Plan{}
+			prov = helper.NewDryRunProvisioner(sess, plan)
+		} else {
+			prov = helper.NewProvisioner(sess)
+		}
+		if Account != "" {
+			prov.Account = Account
+			if plan != nil {
+				plan.SetAccount(Account)
+			}
+		}
+		prov.Timeout = Timeout
+		prov.ENITimeout = ENITimeout
+		prov.Bucket = Bucket
+
+		mode, err := helper.ParseConflictMode(OnConflict)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		prov.OnConflict = mode
+
+		st, err := state.Load(StatePath)
+		if err != nil {
+			fmt.Println("Unable to read state from", StatePath, ":", err.Error())
+			os.Exit(1)
+		}
+		prov.State = st
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if plan != nil {
+			fmt.Println("Planned changes, nothing has been created or deleted:")
+			plan.Print(os.Stdout)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Printf("Version: %v", version)
 	},
 }
 
+// resolveGateway returns the REST API ID of the gateway named by the --name or --id flags
+func resolveGateway(cmd *cobra.Command) (string, error) {
+	if GatewayArgs.Name == "" && GatewayArgs.ID == "" {
+		return "", fmt.Errorf("supply the gateway --name or --id")
+	}
+	return prov.ResolveRestAPI(cmd.Context(), GatewayArgs.Name, GatewayArgs.ID)
+}
+
+// addLambdaRuntimeFlags registers the optional runtime settings of a function, shared by create and update
+func addLambdaRuntimeFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64Var(&LambdaArgs.MemorySize, "memory", 0, "Memory in MB available to the function")
+	cmd.Flags().Int64Var(&LambdaArgs.Timeout, "function-timeout", 0, "Seconds the function may run for before it is stopped")
+	cmd.Flags().StringToStringVar(&LambdaArgs.Environment, "env", nil, "Environment variables as KEY=VALUE, repeat or comma separate for more")
+	cmd.Flags().StringToStringVar(&LambdaArgs.Tags, "tag", nil, "Tags as KEY=VALUE, repeat or comma separate for more")
+	cmd.Flags().StringSliceVar(&LambdaArgs.Layers, "layer", nil, "Layer to add as a version ARN, a name for its latest version or name:version, repeat for more")
+	cmd.Flags().StringVar(&LambdaArgs.TracingMode, "tracing", "", "X-Ray tracing mode, Active or PassThrough")
+	cmd.Flags().StringVar(&LambdaArgs.DeadLetterTarget, "dead-letter-arn", "", "ARN of the SQS queue or SNS topic failed asynchronous invocations are sent to")
+	cmd.Flags().StringVar(&LambdaArgs.KMSKeyArn, "kms-key-arn", "", "ARN of the KMS key the environment variables are encrypted with")
+	cmd.Flags().Int64Var(&ReservedConcurrency, "reserved-concurrency", 0, "Concurrent executions to reserve for the function, which also caps it; 0 throttles it")
+	cmd.Flags().Int64Var(&MaxRetries, "max-retries", 0, "Times a failed asynchronous invocation is retried, 0 to 2")
+	cmd.Flags().Int64Var(&LambdaArgs.MaximumEventAge, "max-event-age", 0, "Seconds an asynchronous invocation is kept for retrying")
+	cmd.Flags().StringVar(&LambdaArgs.OnSuccess, "on-success", "", "ARN of the queue, topic, function or event bus successful asynchronous invocations are sent to")
+	cmd.Flags().StringVar(&LambdaArgs.OnFailure, "on-failure", "", "ARN of the queue, topic, function or event bus failed asynchronous invocations are sent to")
+	cmd.Flags().StringSliceVar(&LambdaArgs.SubnetIDs, "subnet", nil, "ID of a VPC subnet to attach the function to, repeat for more")
+	cmd.Flags().StringSliceVar(&LambdaArgs.SecurityGroupIDs, "security-group", nil, "ID of a security group for the function's network interfaces, repeat for more")
+}
+
+// lambdaArgs returns LambdaArgs with the flags which cannot be bound to it directly applied
+func lambdaArgs(cmd *cobra.Command) helper.Lambda {
+	l := LambdaArgs
+	if cmd.Flags().Changed("reserved-concurrency") {
+		l.ReservedConcurrency = aws.Int64(ReservedConcurrency)
+	}
+	if cmd.Flags().Changed("max-retries") {
+		l.MaximumRetryAttempts = aws.Int64(MaxRetries)
+	}
+	return l
+}
+
+// readArg returns the value of a flag, or the contents of the file it names when it starts with @
+func readArg(value string) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	b, err := ioutil.ReadFile(strings.TrimPrefix(value, "@"))
+	return string(b), err
+}
+
 // Execute ensures the root command is executed and read
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -53,6 +210,14 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&Region, "region", "r", "", "Specify the AWS Region to use.")
 	rootCmd.PersistentFlags().StringVarP(&Account, "account", "a", "", "Account ID to be used")
+	rootCmd.PersistentFlags().StringVar(&StatePath, "state", state.DefaultPath, "File recording the IDs and ARNs of created resources")
+	rootCmd.PersistentFlags().StringVar(&OnConflict, "on-conflict", string(helper.ConflictFail), "What to do when a role or function already exists; adopt, update or fail")
+	rootCmd.PersistentFlags().StringVar(&Bucket, "bucket", "", "S3 bucket to stage deployment packages in, created if missing; by default only packages too large to upload directly are staged")
+	rootCmd.PersistentFlags().StringVar(&S3Endpoint, "s3-endpoint", "", "S3 endpoint URL to stage packages with, such as a local S3-compatible stand-in")
+	rootCmd.PersistentFlags().DurationVar(&Timeout, "timeout", helper.DefaultTimeout, "How long to wait for each resource to be ready or removed")
+
+	cmdCreate.PersistentFlags().BoolVar(&DryRun, "dry-run", false, "Print the calls which would be made without making them")
+	cmdDelete.PersistentFlags().BoolVar(&DryRun, "dry-run", false, "Print the calls which would be made without making them")
 
 	cmdCreateRole.Flags().StringVar(&RoleArgs.RoleName, "name", "default-role"+helper.R(6, "abcdefghi"+"123456789"), "Define role name.")
 	cmdCreateRole.Flags().StringVar(&RoleArgs.Service, "service", "", "Service linked to role, if needed.")
@@ -68,29 +233,130 @@ func init() {
 
 	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Description, "desc", "", "Short description of function")
 	cmdCreateLambda.Flags().StringVar(&LambdaArgs.FunctionName, "name", "default-function"+helper.R(6, "abcdefghi"+"123456789"), "Name for function")
-	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Handler, "handler", "main", "Entrypoint of function")
+	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Handler, "handler", "", "Entrypoint of function, main for go1.x and bootstrap for the provided runtimes by default")
 	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Role, "role", RoleArgs.RoleName, "Link to the role for the service")
-	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Runtime, "runtime", "go1.x", "Lambda runtime to use")
+	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Runtime, "runtime", "go1.x", "Lambda runtime to use, provided or provided.al2 run a Go binary packaged as bootstrap")
 	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Code, "code-path", "", "Path to zip file/deployment package")
+	cmdCreateLambda.Flags().StringVar(&LambdaArgs.Source, "source", "", "Directory of Go source to build the deployment package from, instead of --code-path")
+	addLambdaRuntimeFlags(cmdCreateLambda)
 	cmdCreateLambda.MarkFlagRequired("name")
-	cmdCreateLambda.MarkFlagRequired("handler")
 	cmdCreateLambda.MarkFlagRequired("role")
 	cmdCreateLambda.MarkFlagRequired("runtime")
-	cmdCreateLambda.MarkFlagRequired("code-path")
 
 	cmdCreateGateway.Flags().StringVar(&GatewayArgs.Name, "name", "default-gateway"+helper.R(6, "abcdefghi"+"123456789"), "Name of API Gateway")
 	cmdCreateGateway.Flags().StringVar(&GatewayArgs.Description, "desc", "", "A description for the API Gateway")
 	cmdCreateGateway.Flags().StringVar(&GatewayArgs.FunctionName, "func-name", LambdaArgs.FunctionName, "Supply function name to allow invocation")
+	cmdCreateGateway.Flags().StringVar(&GatewayArgs.Alias, "alias", "", "Alias of the function to invoke, so traffic follows the alias between versions")
 	cmdCreateGateway.MarkFlagRequired("name")
 	cmdCreateGateway.MarkFlagRequired("func-name")
 
+	cmdCreateLayer.Flags().StringVar(&LayerArgs.Name, "name", "", "Name of the layer")
+	cmdCreateLayer.Flags().StringVar(&LayerArgs.Source, "source", "", "Directory of the files to publish, extracted to /opt in the function")
+	cmdCreateLayer.Flags().StringSliceVar(&LayerArgs.Runtimes, "runtimes", nil, "Runtimes the layer is compatible with, comma separate for more")
+	cmdCreateLayer.Flags().StringVar(&LayerArgs.Description, "desc", "", "Short description of the layer version")
+	cmdCreateLayer.MarkFlagRequired("name")
+	cmdCreateLayer.MarkFlagRequired("source")
+
+	cmdCreateTrigger.Flags().StringVar(&TriggerArgs.FunctionName, "function", "", "Name of the function to invoke, optionally qualified as name:alias")
+	cmdCreateTrigger.Flags().StringVar(&TriggerArgs.Source, "source", "", "ARN of the SQS queue, Kinesis stream or DynamoDB stream to read from")
+	cmdCreateTrigger.Flags().Int64Var(&TriggerArgs.BatchSize, "batch-size", 0, "Most records to invoke the function with at once, Lambda's default when 0")
+	cmdCreateTrigger.Flags().StringVar(&TriggerArgs.StartingPosition, "starting-position", "", "Where to start reading a stream, TRIM_HORIZON or LATEST (the default)")
+	cmdCreateTrigger.Flags().BoolVar(&TriggerArgs.Enabled, "enabled", true, "Start invoking the function as soon as the trigger is created")
+	cmdCreateTrigger.MarkFlagRequired("function")
+	cmdCreateTrigger.MarkFlagRequired("source")
+
+	cmdCreateSchedule.Flags().StringVar(&ScheduleArgs.FunctionName, "function", "", "Name of the function to invoke, optionally qualified as name:alias")
+	cmdCreateSchedule.Flags().StringVar(&ScheduleArgs.Expression, "cron", "", "When to invoke the function, six cron fields or a cron(...) or rate(...) expression")
+	cmdCreateSchedule.Flags().StringVar(&ScheduleArgs.Payload, "payload", "", "JSON event to invoke the function with, or @file to read it from a file; the scheduled event by default")
+	cmdCreateSchedule.Flags().StringVar(&ScheduleArgs.Name, "name", "", "Name of the rule, <function>-schedule by default")
+	cmdCreateSchedule.Flags().StringVar(&ScheduleArgs.Description, "desc", "", "Short description of the schedule")
+	cmdCreateSchedule.MarkFlagRequired("function")
+	cmdCreateSchedule.MarkFlagRequired("cron")
+
 	cmdDeleteRole.Flags().StringVar(&RoleArgs.RoleName, "name", "", "The name of the Role to be deleted")
 	cmdDeleteLambda.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function to be deleted")
+	cmdDeleteLambda.Flags().BoolVar(&DeleteSecurityGroups, "delete-security-groups", false, "Also delete the security groups the function was attached to")
+	cmdDeleteLambda.Flags().DurationVar(&ENITimeout, "eni-timeout", helper.DefaultENITimeout, "How long to wait for the network interfaces of a VPC function to be released")
 	cmdDeleteGateway.Flags().StringVar(&GatewayArgs.Name, "name", "", "The name of the Gateway to be deleted")
+	cmdDeleteGateway.Flags().StringVar(&GatewayArgs.ID, "id", "", "The REST API ID of the Gateway, when the name is ambiguous")
 	cmdDeleteRole.MarkFlagRequired("name")
 	cmdDeleteLambda.MarkFlagRequired("name")
-	cmdDeleteGateway.MarkFlagRequired("name")
+	cmdDeleteLayer.Flags().StringVar(&LayerArgs.Name, "name", "", "The name of the Layer to prune")
+	cmdDeleteLayer.Flags().IntVar(&KeepVersions, "keep", 1, "Newest versions to keep, 0 deletes every version")
+	cmdDeleteLayer.MarkFlagRequired("name")
+	cmdDeleteTrigger.Flags().StringVar(&TriggerUUID, "uuid", "", "The UUID of the Trigger to be deleted, see describe trigger")
+	cmdDeleteTrigger.MarkFlagRequired("uuid")
+	cmdDeleteSchedule.Flags().StringVar(&ScheduleArgs.Name, "name", "", "The name of the rule to be deleted")
+	cmdDeleteSchedule.Flags().StringVar(&ScheduleArgs.FunctionName, "function", "", "The Function whose default rule is deleted, when no --name is given")
+
+	cmdDescribeLambda.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function to describe")
+	cmdDescribeLambda.MarkFlagRequired("name")
+	cmdDescribeGateway.Flags().StringVar(&GatewayArgs.Name, "name", "", "The name of the Gateway to describe")
+	cmdDescribeGateway.Flags().StringVar(&GatewayArgs.ID, "id", "", "The REST API ID of the Gateway, when the name is ambiguous")
+	cmdDescribeLayer.Flags().StringVar(&LayerArgs.Name, "name", "", "The name of the Layer to list the versions of")
+	cmdDescribeLayer.MarkFlagRequired("name")
+	cmdDescribeTrigger.Flags().StringVar(&TriggerArgs.FunctionName, "function", "", "The name of the Function to list the triggers of")
+	cmdDescribeTrigger.MarkFlagRequired("function")
+
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function to update")
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.Code, "code-path", "", "Path to a new zip file/deployment package")
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.Source, "source", "", "Directory of Go source to build the new deployment package from")
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.Description, "desc", "", "New short description of function")
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.Handler, "handler", "", "New entrypoint of function")
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.Role, "role", "", "ARN of a new role for the function")
+	cmdUpdateLambda.Flags().StringVar(&LambdaArgs.Runtime, "runtime", "", "New Lambda runtime to use")
+	cmdUpdateLambda.Flags().BoolVar(&Publish, "publish", false, "Publish a new version once updated")
+	cmdUpdateLambda.Flags().StringVar(&Alias, "alias", "", "Alias to point at the new version, implies --publish")
+	addLambdaRuntimeFlags(cmdUpdateLambda)
+	cmdUpdateLambda.Flags().Int64Var(&LambdaArgs.ProvisionedConcurrency, "provisioned-concurrency", 0, "Execution environments to keep initialised for --alias")
+	cmdUpdateLambda.MarkFlagRequired("name")
+
+	cmdDeployLambda.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function to deploy")
+	cmdDeployLambda.Flags().StringVar(&LambdaArgs.Code, "code-path", "", "Path to the new zip file/deployment package")
+	cmdDeployLambda.Flags().StringVar(&LambdaArgs.Source, "source", "", "Directory of Go source to build the new deployment package from")
+	cmdDeployLambda.Flags().StringVar(&Alias, "alias", "live", "Alias to move to the new version")
+	cmdDeployLambda.Flags().StringVar(&CanaryWeight, "canary", "10%", "Percentage of the alias' traffic sent to the new version while it is watched")
+	cmdDeployLambda.Flags().DurationVar(&CanaryInterval, "interval", 5*time.Minute, "How long to watch the new version for errors before promoting it")
+	cmdDeployLambda.Flags().BoolVar(&DryRun, "dry-run", false, "Print the calls which would be made without making them")
+	cmdDeployLambda.MarkFlagRequired("name")
+
+	cmdUpdateGateway.Flags().StringVar(&GatewayArgs.Name, "name", "", "The name of the Gateway to update")
+	cmdUpdateGateway.Flags().StringVar(&GatewayArgs.ID, "id", "", "The REST API ID of the Gateway, when the name is ambiguous")
+	cmdUpdateGateway.Flags().StringVar(&GatewayArgs.Description, "desc", "", "A new description for the API Gateway")
+	cmdUpdateGateway.MarkFlagRequired("desc")
+
+	cmdTestGateway.Flags().StringVar(&GatewayArgs.Name, "name", "", "The name of the Gateway to test")
+	cmdTestGateway.Flags().StringVar(&GatewayArgs.ID, "id", "", "The REST API ID of the Gateway, when the name is ambiguous")
+	cmdTestGateway.Flags().StringVar(&TestBody, "body", "{}", "Request body to post, or @file to read it from a file")
+
+	cmdInvoke.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function to invoke")
+	cmdInvoke.Flags().StringVar(&Alias, "alias", "", "Alias or version of the Function to invoke")
+	cmdInvoke.Flags().StringVar(&Payload, "payload", "{}", "Event to send, or @file to read it from a file")
+	cmdInvoke.Flags().BoolVar(&Async, "async", false, "Queue the invocation instead of waiting for the response")
+	cmdInvoke.Flags().BoolVar(&Proxy, "proxy", false, "Wrap the payload as the body of an API Gateway proxy request")
+	cmdInvoke.Flags().StringVar(&ProxyPath, "path", "", "Request path of the proxy request, /<name> by default")
+	cmdInvoke.MarkFlagRequired("name")
+
+	cmdConcurrency.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function")
+	cmdConcurrency.Flags().Int64Var(&ReservedConcurrency, "reserved", 0, "Concurrent executions to reserve for the function, which also caps it; 0 throttles it")
+	cmdConcurrency.Flags().BoolVar(&Unreserve, "unreserve", false, "Remove the reservation, returning the function to the account's unreserved pool")
+	cmdConcurrency.Flags().StringVar(&Alias, "alias", "", "Alias or version to provision concurrency for")
+	cmdConcurrency.Flags().Int64Var(&ProvisionedConcurrency, "provisioned", 0, "Execution environments to keep initialised for --alias, 0 removes them")
+	cmdConcurrency.MarkFlagRequired("name")
 
-	sess = session.NewSession(Region)
+	cmdLogs.Flags().StringVar(&LambdaArgs.FunctionName, "name", "", "The name of the Function to print the logs of")
+	cmdLogs.Flags().DurationVar(&Since, "since", 10*time.Minute, "How far back to start reading")
+	cmdLogs.Flags().StringVar(&LogFilter, "filter", "", "CloudWatch Logs filter pattern the events must match")
+	cmdLogs.Flags().BoolVar(&Follow, "follow", false, "Keep printing new events as they are logged")
+	cmdLogs.MarkFlagRequired("name")
 
+	cmdApply.Flags().StringVarP(&StackPath, "file", "f", "", "Path to the YAML or JSON stack manifest")
+	cmdApply.Flags().BoolVar(&DryRun, "dry-run", false, "Print the calls which would be made without making them")
+	cmdApply.MarkFlagRequired("file")
+	cmdDestroy.Flags().StringVarP(&StackPath, "file", "f", "", "Path to the YAML or JSON stack manifest")
+	cmdDestroy.Flags().BoolVar(&DryRun, "dry-run", false, "Print the calls which would be made without making them")
+	cmdDestroy.MarkFlagRequired("file")
+	cmdPlan.Flags().StringVarP(&StackPath, "file", "f", "", "Path to the YAML or JSON stack manifest")
+	cmdPlan.Flags().BoolVar(&PlanDestroy, "destroy", false, "Plan tearing the stack down rather than creating it")
+	cmdPlan.MarkFlagRequired("file")
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdStatus)
+}
+
+var cmdStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show the resources recorded in the state file",
+	Long: `Status lists every role, function and gateway recorded in the state file along with
+				the ARNs and IDs created for them, including the triggers and schedules of each function,
+				without calling AWS.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		st := prov.State
+		fmt.Println("State: ", st.Path())
+
+		roles := make([]string, 0, len(st.Roles))
+		for name := range st.Roles {
+			roles = append(roles, name)
+		}
+		sort.Strings(roles)
+		for _, name := range roles {
+			r := st.Roles[name]
+			fmt.Printf("role %s\n  arn: %s\n", name, r.Arn)
+			if len(r.Policies) > 0 {
+				fmt.Printf("  policies: %s\n", strings.Join(r.Policies, ", "))
+			}
+		}
+
+		functions := make([]string, 0, len(st.Functions))
+		for name := range st.Functions {
+			functions = append(functions, name)
+		}
+		sort.Strings(functions)
+		for _, name := range functions {
+			f := st.Functions[name]
+			fmt.Printf("function %s\n  arn: %s\n  role: %s\n", name, f.Arn, f.Role)
+			if len(f.Permissions) > 0 {
+				fmt.Printf("  permissions: %s\n", strings.Join(f.Permissions, ", "))
+			}
+			aliases := make([]string, 0, len(f.Aliases))
+			for alias := range f.Aliases {
+				aliases = append(aliases, alias)
+			}
+			sort.Strings(aliases)
+			for _, alias := range aliases {
+				fmt.Printf("  alias %s: version %s\n", alias, f.Aliases[alias])
+			}
+			if len(f.Triggers) > 0 {
+				fmt.Printf("  triggers: %s\n", strings.Join(f.Triggers, ", "))
+			}
+			if len(f.TriggerPolicies) > 0 {
+				fmt.Printf("  trigger policies: %s\n", strings.Join(f.TriggerPolicies, ", "))
+			}
+			if len(f.Schedules) > 0 {
+				fmt.Printf("  schedules: %s\n", strings.Join(f.Schedules, ", "))
+			}
+		}
+
+		gateways := make([]string, 0, len(st.Gateways))
+		for name := range st.Gateways {
+			gateways = append(gateways, name)
+		}
+		sort.Strings(gateways)
+		for _, name := range gateways {
+			g := st.Gateways[name]
+			fmt.Printf("gateway %s\n  rest api: %s\n  root resource: %s\n  resource: %s\n  deployment: %s\n  stage: %s\n  function: %s\n",
+				name, g.RestAPIID, g.RootResourceID, g.ResourceID, g.DeploymentID, g.Stage, g.FunctionName)
+		}
+	},
+}
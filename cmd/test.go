@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdTest)
+	cmdTest.AddCommand(cmdTestGateway)
+}
+
+var cmdTest = &cobra.Command{
+	Use:   "test [resource]",
+	Short: "Test an existing AWS resource",
+	Long:  "Use this command to check a deployed resource responds as expected, the resource is specified in a subcommand",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Supply a subcommand to test a resource")
+	},
+}
+
+var cmdTestGateway = &cobra.Command{
+	Use:   "gateway [flags]",
+	Short: "Post a request to an API Gateway endpoint",
+	Long: `This subcommand posts the body to the endpoint of the given gateway twice; once through
+			API Gateway's TestInvokeMethod, which shows the integration log, and once as a real HTTPS
+			request to the prod stage. Prefix the body with @ to read it from a file. It exits non-zero
+			unless both return 200.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		body, err := readArg(TestBody)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		id, err := resolveGateway(cmd)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		res, err := prov.TestAPI(cmd.Context(), id, body)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("test invoke\n  status: %d\n  latency: %dms\n  body: %s\n",
+			aws.Int64Value(res.Invoke.Status), aws.Int64Value(res.Invoke.Latency), aws.StringValue(res.Invoke.Body))
+		fmt.Println("  log:")
+		for _, line := range res.LogExcerpt() {
+			fmt.Println("    " + line)
+		}
+		fmt.Printf("POST %s\n  status: %d\n  latency: %s\n  body: %s\n", res.URL, res.Status, res.Latency, res.Body)
+
+		if !res.OK() {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(cmdUpdate)
+	cmdUpdate.AddCommand(cmdUpdateLambda)
+	cmdUpdate.AddCommand(cmdUpdateGateway)
+}
+
+var cmdUpdate = &cobra.Command{
+	Use:   "update [resource to change]",
+	Short: "Update tells the program to change an existing resource",
+	Long:  "Use this command to change the configuration of a resource which already exists, the resource is specified in a subcommand",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Supply a subcommand to update a resource")
+	},
+}
+
+var cmdUpdateLambda = &cobra.Command{
+	Use:   "lambda [flags]",
+	Short: "Update an existing Lambda function",
+	Long: `This subcommand changes the code and configuration of an existing function in place, so
+			the permissions allowing API Gateway to invoke it are kept. Only the flags given are changed.
+			Use --publish to publish a version and --alias to point a named alias at the new version.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		lmb, err := prov.UpdateLambda(cmd.Context(), lambdaArgs(cmd), Publish, Alias)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Function updated: ", lmb)
+		if Alias != "" {
+			fmt.Printf("Alias %s points at version %s\n", Alias, aws.StringValue(lmb.Version))
+		}
+	},
+}
+
+var cmdUpdateGateway = &cobra.Command{
+	Use:   "gateway [flags]",
+	Short: "Update an API Gateway REST API",
+	Long: `This subcommand changes the description of the REST API of the given name or ID,
+			supply the ID when more than one REST API shares the name.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := resolveGateway(cmd)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		gwy, err := prov.UpdateGateway(cmd.Context(), id, GatewayArgs)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Gateway (REST API) updated: ", gwy)
+	},
+}
@@ -0,0 +1,114 @@
+// Package deploy builds and checks the zip files uploaded to Lambda as deployment packages
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// GOOS and GOARCH are the platform Build compiles for, the one the go1.x runtime runs on
+	GOOS   = "linux"
+	GOARCH = "amd64"
+)
+
+// epoch is the modification time given to every file in a package, so the same files always zip to the same bytes
+var epoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// File is a single file in a deployment package
+type File struct {
+	Name string
+	Mode os.FileMode
+	Body []byte
+}
+
+// Build compiles the Go main package in dir for linux/amd64 and returns it as a deployment package for the
+// runtime, with the binary named after the handler for go1.x or bootstrap for the provided runtimes. Builds for
+// the provided runtimes use the lambda.norpc tag, as aws-lambda-go only needs its RPC server on go1.x. The build
+// is stripped of paths and build IDs so unchanged source gives an identical package
+func Build(ctx context.Context, dir, handler, runtime string) ([]byte, error) {
+	tmp, err := ioutil.TempDir("", "lambda-build")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	name := Entry(handler, runtime)
+	bin := filepath.Join(tmp, name)
+	args := []string{"build", "-trimpath", "-ldflags=-s -w -buildid=", "-o", bin}
+	if IsProvided(runtime) {
+		args = append(args, "-tags=lambda.norpc")
+	}
+	cmd := exec.CommandContext(ctx, "go", append(args, ".")...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+GOOS, "GOARCH="+GOARCH, "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("building %s: %w\n%s", dir, err, out)
+	}
+
+	b, err := ioutil.ReadFile(bin)
+	if err != nil {
+		return nil, err
+	}
+	return Zip(File{Name: name, Mode: 0755, Body: b})
+}
+
+// ZipDir returns the files under dir as a zip archive, named by their path relative to dir with forward
+// slashes as zip requires, and keeping their modes
+func ZipDir(dir string) ([]byte, error) {
+	var files []File
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Name: filepath.ToSlash(rel), Mode: info.Mode(), Body: b})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no files to zip", dir)
+	}
+	return Zip(files...)
+}
+
+// Zip returns the files as a zip archive, in name order and with fixed timestamps so the archive only
+// changes when the files do. The mode of each file is kept so executables stay executable on Lambda
+func Zip(files ...File) ([]byte, error) {
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fh := &zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: epoch}
+		fh.SetMode(f.Mode)
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.Body); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestZip(t *testing.T) {
+	files := []File{{Name: "main", Mode: 0755, Body: []byte("binary")}, {Name: "config.json", Mode: 0644, Body: []byte("{}")}}
+	first, err := Zip(files...)
+	if err != nil {
+		t.Fatalf("Zip failed: %v", err)
+	}
+	second, _ := Zip(files[1], files[0])
+	if !bytes.Equal(first, second) {
+		t.Errorf("Zip failed, expected the same files to give the same archive")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		name string
+		mode os.FileMode
+	}{{"config.json", 0644}, {"main", 0755}} {
+		got := zr.File[i]
+		if got.Name != want.name || got.Mode() != want.mode || !got.Modified.Equal(epoch) {
+			t.Errorf("Zip failed, expected %s with mode %v, got %s with mode %v modified %v", want.name, want.mode, got.Name, got.Mode(), got.Modified)
+		}
+	}
+}
+
+func TestZipDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "bin", "helper"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "ca.pem"), []byte("cert"), 0644)
+
+	pkg, err := ZipDir(dir)
+	if err != nil {
+		t.Fatalf("ZipDir failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "bin/helper" || zr.File[0].Mode()&0111 == 0 || zr.File[1].Name != "ca.pem" {
+		t.Errorf("ZipDir failed, expected an executable bin/helper and ca.pem, got %v", zr.File)
+	}
+
+	empty, _ := ioutil.TempDir("", "empty")
+	defer os.RemoveAll(empty)
+	if _, err := ZipDir(empty); err == nil {
+		t.Errorf("ZipDir expected an error for an empty directory")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module hello\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	pkg, err := Build(context.Background(), dir, "main", "go1.x")
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	again, err := Build(context.Background(), dir, "main", "go1.x")
+	if err != nil || !bytes.Equal(pkg, again) {
+		t.Errorf("Build failed, expected a reproducible package, got %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "main" || zr.File[0].Mode()&0111 == 0 {
+		t.Errorf("Build failed, expected an executable main, got %v", zr.File)
+	}
+
+	pkg, err = Build(context.Background(), dir, "main", "provided.al2")
+	if err != nil {
+		t.Fatalf("Build for provided.al2 failed: %v", err)
+	}
+	if err := Validate(pkg, "main", "provided.al2"); err != nil {
+		t.Errorf("Build for provided.al2 failed, expected a bootstrap package, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package deploy
+
+// Bootstrap is the file the provided runtimes run, from the root of the package, whatever the handler is
+const Bootstrap = "bootstrap"
+
+// providedRuntimes are the custom runtimes, which run Bootstrap rather than loading the handler themselves
+var providedRuntimes = map[string]bool{
+	"provided":     true,
+	"provided.al2": true,
+}
+
+// IsProvided reports whether runtime is one of the custom runtimes
+func IsProvided(runtime string) bool {
+	return providedRuntimes[runtime]
+}
+
+// Handler returns the handler to configure for the runtime when none is given; main, the conventional name of
+// the binary for go1.x, or bootstrap for the provided runtimes. Other runtimes have no default
+func Handler(handler, runtime string) string {
+	switch {
+	case handler != "":
+		return handler
+	case IsProvided(runtime):
+		return Bootstrap
+	case runtime == "go1.x":
+		return "main"
+	}
+	return ""
+}
+
+// Entry returns the name of the file in the package the runtime starts for the handler; bootstrap for the
+// provided runtimes and the binary the handler names for go1.x
+func Entry(handler, runtime string) string {
+	if IsProvided(runtime) {
+		return Bootstrap
+	}
+	return handler
+}
+
+// Buildable reports whether Build can produce a package the runtime runs
+func Buildable(runtime string) bool {
+	return runtime == "go1.x" || IsProvided(runtime)
+}
@@ -0,0 +1,129 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrInvalidPackage is returned by Validate for a package Lambda would accept but could not run
+var ErrInvalidPackage = errors.New("invalid deployment package")
+
+// machines maps a GOARCH to the ELF machine a binary built for it has
+var machines = map[string]elf.Machine{
+	"amd64": elf.EM_X86_64,
+	"arm64": elf.EM_AARCH64,
+}
+
+// Validate checks pkg is a zip containing the file the handler of the runtime loads. For go1.x the handler
+// names a binary, which must be executable and a linux ELF binary for GOARCH; a binary built on a mac or
+// without GOOS=linux is the usual culprit. The provided runtimes ignore the handler and run bootstrap, which
+// must be an executable script or such a binary. Java and .NET handlers name classes inside jars and
+// assemblies, which are not looked into. For the other runtimes the handler is module.function, where a dotted
+// module is a path as in Python's package.module, and a file for the module must be present
+func Validate(pkg []byte, handler, runtime string) error {
+	zr, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		return fmt.Errorf("%w: not a zip archive: %v", ErrInvalidPackage, err)
+	}
+
+	if IsProvided(runtime) {
+		return validateBootstrap(zr, runtime)
+	}
+	if strings.HasPrefix(runtime, "java") || strings.HasPrefix(runtime, "dotnet") {
+		return nil
+	}
+	if runtime != "go1.x" {
+		module := handler
+		if i := strings.LastIndex(handler, "."); i > 0 {
+			module = strings.Replace(handler[:i], ".", "/", -1)
+		}
+		for _, f := range zr.File {
+			if strings.TrimSuffix(f.Name, path.Ext(f.Name)) == module {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: no file for handler %s in the zip", ErrInvalidPackage, handler)
+	}
+
+	var bin *zip.File
+	for _, f := range zr.File {
+		if f.Name == handler {
+			bin = f
+		}
+	}
+	if bin == nil {
+		for _, f := range zr.File {
+			if f.Name == Bootstrap {
+				return fmt.Errorf("%w: handler %s not found in the zip, which has the %s of a provided runtime", ErrInvalidPackage, handler, Bootstrap)
+			}
+		}
+		return fmt.Errorf("%w: handler %s not found in the zip", ErrInvalidPackage, handler)
+	}
+	if bin.Mode()&0111 == 0 {
+		return fmt.Errorf("%w: handler %s is not executable, it has mode %v", ErrInvalidPackage, handler, bin.Mode())
+	}
+	body, err := read(bin)
+	if err != nil {
+		return err
+	}
+	return checkBinary(bin.Name, body, GOARCH)
+}
+
+// validateBootstrap checks the zip has the executable bootstrap file at its root the provided runtimes run
+func validateBootstrap(zr *zip.Reader, runtime string) error {
+	var bin *zip.File
+	for _, f := range zr.File {
+		if f.Name == Bootstrap {
+			bin = f
+		}
+	}
+	if bin == nil {
+		return fmt.Errorf("%w: the %s runtime runs %s from the root of the zip, which is missing; name the binary %s",
+			ErrInvalidPackage, runtime, Bootstrap, Bootstrap)
+	}
+	if bin.Mode()&0111 == 0 {
+		return fmt.Errorf("%w: %s is not executable, it has mode %v", ErrInvalidPackage, Bootstrap, bin.Mode())
+	}
+	body, err := read(bin)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(body, []byte("#!")) {
+		return nil
+	}
+	return checkBinary(bin.Name, body, GOARCH)
+}
+
+// read returns the contents of a zipped file
+func read(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidPackage, f.Name, err)
+	}
+	defer rc.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(rc); err != nil {
+		return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidPackage, f.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// checkBinary checks the named file is an ELF binary for linux on arch
+func checkBinary(name string, body []byte, arch string) error {
+	bin, err := elf.NewFile(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %s is not a linux ELF binary, build it with GOOS=linux GOARCH=%s", ErrInvalidPackage, name, arch)
+	}
+	if bin.OSABI != elf.ELFOSABI_NONE && bin.OSABI != elf.ELFOSABI_LINUX {
+		return fmt.Errorf("%w: %s is built for %v, build it with GOOS=linux", ErrInvalidPackage, name, bin.OSABI)
+	}
+	if bin.Machine != machines[arch] {
+		return fmt.Errorf("%w: %s is built for %v, build it with GOARCH=%s", ErrInvalidPackage, name, bin.Machine, arch)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package deploy
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// elfHeader returns the smallest ELF file debug/elf accepts, a 64 bit header for the machine with no sections
+func elfHeader(osabi elf.OSABI, machine elf.Machine) []byte {
+	b := make([]byte, 64)
+	copy(b, elf.ELFMAG)
+	b[elf.EI_CLASS], b[elf.EI_DATA], b[elf.EI_VERSION], b[elf.EI_OSABI] = byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT), byte(osabi)
+	binary.LittleEndian.PutUint16(b[16:], uint16(elf.ET_EXEC))
+	binary.LittleEndian.PutUint16(b[18:], uint16(machine))
+	binary.LittleEndian.PutUint32(b[20:], uint32(elf.EV_CURRENT))
+	return b
+}
+
+func TestValidate(t *testing.T) {
+	linux := elfHeader(elf.ELFOSABI_NONE, elf.EM_X86_64)
+	tests := []struct {
+		name    string
+		files   []File
+		handler string
+		runtime string
+		valid   bool
+	}{
+		{"go binary", []File{{"main", 0755, linux}}, "main", "go1.x", true},
+		{"missing handler", []File{{"main", 0755, linux}}, "bootstrap", "go1.x", false},
+		{"not executable", []File{{"main", 0644, linux}}, "main", "go1.x", false},
+		{"mac binary", []File{{"main", 0755, []byte{0xcf, 0xfa, 0xed, 0xfe, 7, 0, 0, 1}}}, "main", "go1.x", false},
+		{"freebsd binary", []File{{"main", 0755, elfHeader(elf.ELFOSABI_FREEBSD, elf.EM_X86_64)}}, "main", "go1.x", false},
+		{"arm binary", []File{{"main", 0755, elfHeader(elf.ELFOSABI_NONE, elf.EM_AARCH64)}}, "main", "go1.x", false},
+		{"go binary for provided", []File{{"bootstrap", 0755, linux}}, "main", "go1.x", false},
+		{"provided bootstrap", []File{{"bootstrap", 0755, linux}}, "bootstrap", "provided.al2", true},
+		{"provided ignores handler", []File{{"bootstrap", 0755, linux}}, "main", "provided", true},
+		{"provided script", []File{{"bootstrap", 0755, []byte("#!/bin/sh\n")}}, "bootstrap", "provided.al2", true},
+		{"provided without bootstrap", []File{{"main", 0755, linux}}, "main", "provided.al2", false},
+		{"provided not executable", []File{{"bootstrap", 0644, linux}}, "bootstrap", "provided.al2", false},
+		{"provided mac binary", []File{{"bootstrap", 0755, []byte{0xcf, 0xfa, 0xed, 0xfe, 7, 0, 0, 1}}}, "bootstrap", "provided.al2", false},
+		{"python module", []File{{"app/index.py", 0644, []byte("def handler(e, c): pass")}}, "app/index.handler", "python3.8", true},
+		{"python missing module", []File{{"index.py", 0644, nil}}, "main.handler", "python3.8", false},
+		{"python package", []File{{"app/main.py", 0644, nil}}, "app.main.handler", "python3.8", true},
+		{"node module", []File{{"src/index.js", 0644, nil}}, "src/index.handler", "nodejs12.x", true},
+		{"java class", []File{{"lib/app.jar", 0644, nil}}, "example.Handler::handleRequest", "java11", true},
+		{"dotnet assembly", []File{{"MyAssembly.dll", 0644, nil}}, "MyAssembly::MyNs.MyClass::Handle", "dotnetcore3.1", true},
+	}
+	for _, tt := range tests {
+		pkg, err := Zip(tt.files...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Validate(pkg, tt.handler, tt.runtime)
+		if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrInvalidPackage)) {
+			t.Errorf("Validate with %s failed, expected valid %v, got %v", tt.name, tt.valid, err)
+		}
+	}
+
+	if err := Validate([]byte("not a zip"), "main", "go1.x"); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("Validate failed, expected %v for a file which is not a zip, got %v", ErrInvalidPackage, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		handler, runtime string
+		want, entry      string
+	}{
+		{"", "go1.x", "main", "main"},
+		{"hello", "go1.x", "hello", "hello"},
+		{"", "provided.al2", "bootstrap", "bootstrap"},
+		{"main", "provided", "main", "bootstrap"},
+		{"", "python3.8", "", ""},
+	}
+	for _, tt := range tests {
+		if got := Handler(tt.handler, tt.runtime); got != tt.want {
+			t.Errorf("Handler(%q, %q) failed, expected %q, got %q", tt.handler, tt.runtime, tt.want, got)
+		}
+		if got := Entry(Handler(tt.handler, tt.runtime), tt.runtime); got != tt.entry {
+			t.Errorf("Entry for %q on %q failed, expected %q, got %q", tt.handler, tt.runtime, tt.entry, got)
+		}
+	}
+}
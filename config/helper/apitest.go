@@ -0,0 +1,116 @@
+package helper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// stageURL returns the base URL a deployed stage of a REST API is served from
+var stageURL = func(apiID, region, stage string) string {
+	return "https://" + apiID + ".execute-api." + region + ".amazonaws.com/" + stage
+}
+
+// integrationLogMarkers pick out the lines of a TestInvokeMethod log which describe the call to the integration
+var integrationLogMarkers = []string{"Endpoint request", "Endpoint response", "Execution failed", "Method completed"}
+
+// APITestResult is the outcome of TestAPI; the test invocation made through API Gateway and the real request
+// made to the stage URL
+type APITestResult struct {
+	Invoke  *apigateway.TestInvokeMethodOutput
+	URL     string
+	Status  int
+	Latency time.Duration
+	Body    string
+}
+
+// OK reports whether both the test invocation and the request to the stage URL returned 200
+func (r *APITestResult) OK() bool {
+	return aws.Int64Value(r.Invoke.Status) == http.StatusOK && r.Status == http.StatusOK
+}
+
+// LogExcerpt returns the lines of the test invocation log describing the call to the integration
+func (r *APITestResult) LogExcerpt() []string {
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(aws.StringValue(r.Invoke.Log)))
+	for sc.Scan() {
+		for _, marker := range integrationLogMarkers {
+			if strings.Contains(sc.Text(), marker) {
+				lines = append(lines, sc.Text())
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// TestAPI posts body to the endpoint ConfigureAPIEndpoint set up on the REST API with the given ID, first with
+// TestInvokeMethod, which bypasses the deployment and returns the execution log, then as a real HTTPS request to
+// the prod stage. A non-200 status is not an error, check OK on the result
+func (p *Provisioner) TestAPI(ctx context.Context, id, body string) (*APITestResult, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	d, err := p.DescribeGateway(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.endpointResource(d)
+	if err != nil {
+		return nil, err
+	}
+
+	invoke, err := p.APIGatewaySvc.TestInvokeMethodWithContext(ctx, &apigateway.TestInvokeMethodInput{
+		RestApiId:  aws.String(id),
+		ResourceId: res.Id,
+		HttpMethod: aws.String("POST"),
+		Body:       aws.String(body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("test invoking %s: %w", aws.StringValue(res.Path), err)
+	}
+	result := &APITestResult{Invoke: invoke, URL: stageURL(id, p.Region, "prod") + aws.StringValue(res.Path)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, result.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("posting to %s: %w", result.URL, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	result.Latency = time.Since(start)
+	if err != nil {
+		return result, fmt.Errorf("reading response from %s: %w", result.URL, err)
+	}
+	result.Status, result.Body = resp.StatusCode, string(b)
+	return result, nil
+}
+
+// endpointResource returns the resource ConfigureAPIEndpoint created, the one recorded in the state or else
+// the one named after the REST API
+func (p *Provisioner) endpointResource(d *GatewayDescription) (*apigateway.Resource, error) {
+	var resID string
+	if p.State != nil {
+		if _, g, ok := p.State.GatewayByID(aws.StringValue(d.API.Id)); ok {
+			resID = g.ResourceID
+		}
+	}
+	for _, r := range d.Resources {
+		if (resID != "" && aws.StringValue(r.Id) == resID) || aws.StringValue(r.Path) == "/"+aws.StringValue(d.API.Name) {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("REST API %s has no endpoint resource /%s", aws.StringValue(d.API.Id), aws.StringValue(d.API.Name))
+}
@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+func TestTestAPI(t *testing.T) {
+	p, _, _, g := newFakeProvisioner()
+	ctx := context.Background()
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = r.Method + " " + r.URL.Path + " " + string(b)
+		if string(b) == "bad" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	defer func(f func(string, string, string) string) { stageURL = f }(stageURL)
+	stageURL = func(apiID, region, stage string) string { return srv.URL + "/" + apiID + "/" + stage }
+
+	g.apis["a1"] = &apigateway.RestApi{Id: aws.String("a1"), Name: aws.String("stack-api")}
+	g.resources["a1"] = []*apigateway.Resource{{Id: aws.String("r1"), Path: aws.String("/stack-api")}}
+	g.invoked["r1"] = &apigateway.TestInvokeMethodOutput{
+		Status: aws.Int64(200),
+		Log:    aws.String("Execution log for request abc\nMethod request body before transformations: {}\nEndpoint response body before transformations: {}\nMethod completed with status: 200\n"),
+	}
+
+	res, err := p.TestAPI(ctx, "a1", `{"operation": "create"}`)
+	if err != nil {
+		t.Fatalf("TestAPI failed: %v", err)
+	}
+	if want := `POST /a1/prod/stack-api {"operation": "create"}`; got != want {
+		t.Errorf("TestAPI failed, expected request %q, got %q", want, got)
+	}
+	if !res.OK() || res.Body != `{"ok":true}` {
+		t.Errorf("TestAPI failed, expected a 200 with the response body, got %d %q", res.Status, res.Body)
+	}
+	if excerpt := res.LogExcerpt(); len(excerpt) != 2 {
+		t.Errorf("LogExcerpt failed, expected the endpoint response and completion lines, got %q", excerpt)
+	}
+
+	res, err = p.TestAPI(ctx, "a1", "bad")
+	if err != nil || res.OK() {
+		t.Errorf("TestAPI failed, expected a result which is not OK for a 502, got %v %v", res, err)
+	}
+
+	g.apis["b2"] = &apigateway.RestApi{Id: aws.String("b2"), Name: aws.String("bare-api")}
+	if _, err := p.TestAPI(ctx, "b2", "{}"); err == nil {
+		t.Errorf("TestAPI expected an error for a REST API without an endpoint resource")
+	}
+}
@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// destinationServices are the services whose resources can receive the outcome of an asynchronous invocation
+var destinationServices = []string{"sqs", "sns", "lambda", "events"}
+
+// FunctionDescription is a function's configuration and, when it has one, its asynchronous invocation config
+type FunctionDescription struct {
+	Configuration *lambda.FunctionConfiguration
+	InvokeConfig  *lambda.GetFunctionEventInvokeConfigOutput
+}
+
+// DescribeLambda returns the configuration of the function and how it handles asynchronous invocations
+func (p *Provisioner) DescribeLambda(ctx context.Context, funcName string) (*FunctionDescription, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	fn, err := p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(funcName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking up function %s: %w", funcName, err)
+	}
+	d := &FunctionDescription{Configuration: fn}
+
+	d.InvokeConfig, err = p.LambdaSvc.GetFunctionEventInvokeConfigWithContext(ctx, &lambda.GetFunctionEventInvokeConfigInput{
+		FunctionName: aws.String(funcName),
+	})
+	if isNotFound(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("looking up the asynchronous invocation config of function %s: %w", funcName, err)
+	}
+	return d, nil
+}
+
+// invokeConfig returns the asynchronous invocation settings of l, or nil when l sets none
+func (l Lambda) invokeConfig() *lambda.UpdateFunctionEventInvokeConfigInput {
+	in := &lambda.UpdateFunctionEventInvokeConfigInput{
+		FunctionName:         aws.String(l.FunctionName),
+		MaximumRetryAttempts: l.MaximumRetryAttempts,
+	}
+	if l.MaximumEventAge > 0 {
+		in.MaximumEventAgeInSeconds = aws.Int64(l.MaximumEventAge)
+	}
+	if l.OnSuccess != "" || l.OnFailure != "" {
+		in.DestinationConfig = &lambda.DestinationConfig{}
+	}
+	if l.OnSuccess != "" {
+		in.DestinationConfig.OnSuccess = &lambda.OnSuccess{Destination: aws.String(l.OnSuccess)}
+	}
+	if l.OnFailure != "" {
+		in.DestinationConfig.OnFailure = &lambda.OnFailure{Destination: aws.String(l.OnFailure)}
+	}
+
+	if in.MaximumRetryAttempts == nil && in.MaximumEventAgeInSeconds == nil && in.DestinationConfig == nil {
+		return nil
+	}
+	return in
+}
+
+// checkDestinations reports an error for a destination of l which cannot receive invocation records
+func (l Lambda) checkDestinations() error {
+	for _, dest := range []string{l.OnSuccess, l.OnFailure} {
+		if dest == "" {
+			continue
+		}
+		a, err := arn.Parse(dest)
+		if err != nil {
+			return fmt.Errorf("function %s: destination %s is not an ARN: %w", l.FunctionName, dest, err)
+		}
+		if !contains(destinationServices, a.Service) {
+			return fmt.Errorf("function %s: destination %s must be an SQS queue, SNS topic, Lambda function or EventBridge bus, not from %s",
+				l.FunctionName, dest, a.Service)
+		}
+	}
+	return nil
+}
+
+// setInvokeConfig changes the asynchronous invocation settings of the function to those l sets, leaving the
+// others as they are. The function's role needs permission to send to the destinations, Lambda checks this
+// when they are set, check them with checkDestinations first
+func (p *Provisioner) setInvokeConfig(ctx context.Context, l Lambda) error {
+	in := l.invokeConfig()
+	if in == nil {
+		return nil
+	}
+	_, err := p.LambdaSvc.UpdateFunctionEventInvokeConfigWithContext(ctx, in)
+	if isNotFound(err) {
+		_, err = p.LambdaSvc.PutFunctionEventInvokeConfigWithContext(ctx, &lambda.PutFunctionEventInvokeConfigInput{
+			FunctionName:             in.FunctionName,
+			MaximumRetryAttempts:     in.MaximumRetryAttempts,
+			MaximumEventAgeInSeconds: in.MaximumEventAgeInSeconds,
+			DestinationConfig:        in.DestinationConfig,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("setting the asynchronous invocation config of function %s: %w", l.FunctionName, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestInvokeConfig(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	code, _ := writePackage(t, "main")
+	l := Lambda{
+		FunctionName: "stack-action", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/r", Code: code,
+		MaximumRetryAttempts: aws.Int64(0), OnFailure: "arn:aws:sqs:eu-west-2:123456789012:stack-failures",
+	}
+
+	bad := l
+	bad.OnSuccess = "arn:aws:s3:::stack-bucket"
+	if _, err := p.CreateLambda(ctx, bad); err == nil || fl.functions["stack-action"] != nil {
+		t.Errorf("CreateLambda expected an error before creating a function with an S3 destination, got %v", err)
+	}
+
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	if _, err := p.UpdateLambda(ctx, Lambda{FunctionName: "stack-action", MaximumEventAge: 3600}, false, ""); err != nil {
+		t.Fatalf("UpdateLambda failed: %v", err)
+	}
+
+	d, err := p.DescribeLambda(ctx, "stack-action")
+	if err != nil {
+		t.Fatalf("DescribeLambda failed: %v", err)
+	}
+	cfg := d.InvokeConfig
+	if cfg == nil || aws.Int64Value(cfg.MaximumRetryAttempts) != 0 || aws.Int64Value(cfg.MaximumEventAgeInSeconds) != 3600 ||
+		aws.StringValue(cfg.DestinationConfig.OnFailure.Destination) != l.OnFailure {
+		t.Errorf("DescribeLambda failed, expected no retries for an hour with failures sent to %s, got %v", l.OnFailure, cfg)
+	}
+
+	fl.functions["stack-plain"] = &lambda.FunctionConfiguration{FunctionName: aws.String("stack-plain")}
+	if d, err := p.DescribeLambda(ctx, "stack-plain"); err != nil || d.InvokeConfig != nil {
+		t.Errorf("DescribeLambda failed, expected no invoke config, got %v, %v", d, err)
+	}
+}
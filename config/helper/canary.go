@@ -0,0 +1,206 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// canaryCheckInterval is how often the error metric of a canary version is read, Lambda publishes its metrics
+// at one minute resolution so checking more often finds nothing new
+var canaryCheckInterval = time.Minute
+
+// ErrCanaryFailed is returned by DeployLambda when the new version reported errors and the alias was rolled back
+var ErrCanaryFailed = errors.New("canary version reported errors")
+
+// Canary describes how DeployLambda moves an alias to a new version; Weight, between 0 and 1, is the share of
+// the alias' invocations routed to the new version while its errors are watched for Interval
+type Canary struct {
+	Alias    string
+	Weight   float64
+	Interval time.Duration
+}
+
+// DeployLambda publishes a new version of l.FunctionName with the changes in l, then routes c.Weight of the
+// traffic of alias c.Alias to it for c.Interval while the version's Errors metric is watched. With no errors the
+// alias is promoted to the new version, otherwise it is rolled back to the version it pointed at before and
+// ErrCanaryFailed is returned. When ctx is done first the alias is rolled back too, and ctx's error is returned
+// instead so a cancelled deploy can be told apart from a failing version. An alias which does not exist yet is
+// simply created at the new version, as there is no live version to compare against. Only callers invoking the
+// alias, such as a gateway created with an alias, see the canary
+func (p *Provisioner) DeployLambda(ctx context.Context, l Lambda, c Canary) (*lambda.FunctionConfiguration, error) {
+	if c.Weight <= 0 || c.Weight >= 1 {
+		return nil, fmt.Errorf("canary weight must be between 0 and 1, got %v", c.Weight)
+	}
+
+	live, err := p.aliasVersion(ctx, l.FunctionName, c.Alias)
+	if isNotFound(err) {
+		fmt.Printf("Alias %s does not exist, creating it without a canary\n", c.Alias)
+		return p.UpdateLambda(ctx, l, true, c.Alias)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("looking up alias %s of function %s: %w", c.Alias, l.FunctionName, err)
+	}
+
+	fn, err := p.UpdateLambda(ctx, l, true, "")
+	if err != nil {
+		return nil, err
+	}
+	version := aws.StringValue(fn.Version)
+	if version == live {
+		fmt.Printf("Alias %s already points at version %s, nothing to deploy\n", c.Alias, version)
+		return fn, nil
+	}
+
+	if err := p.routeAlias(ctx, l.FunctionName, c.Alias, live, version, c.Weight); err != nil {
+		return fn, err
+	}
+	fmt.Printf("Routing %v%% of alias %s to version %s for %s\n", c.Weight*100, c.Alias, version, c.Interval)
+
+	if err := p.watchCanary(ctx, l.FunctionName, c, version); err != nil {
+		// ctx may be the reason the canary stopped, so the rollback runs under a fresh timeout
+		rctx, cancel := p.withTimeout(context.Background())
+		defer cancel()
+		if rerr := p.pointAlias(rctx, l.FunctionName, c.Alias, live); rerr != nil {
+			return fn, fmt.Errorf("%w (rolling back to version %s: %v)", err, live, rerr)
+		}
+		return fn, fmt.Errorf("%w (alias %s rolled back to version %s)", err, c.Alias, live)
+	}
+
+	pctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	if err := p.pointAlias(pctx, l.FunctionName, c.Alias, version); err != nil {
+		return fn, err
+	}
+	return fn, nil
+}
+
+// publishAlias publishes the current code of a function as a version and points the alias at it
+func (p *Provisioner) publishAlias(ctx context.Context, funcName, alias string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.LambdaSvc.PublishVersionWithContext(ctx, &lambda.PublishVersionInput{FunctionName: aws.String(funcName)})
+	if err != nil {
+		return fmt.Errorf("publishing function %s: %w", funcName, err)
+	}
+	return p.pointAlias(ctx, funcName, alias, aws.StringValue(res.Version))
+}
+
+// aliasVersion returns the version an alias points at
+func (p *Provisioner) aliasVersion(ctx context.Context, funcName, alias string) (string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.LambdaSvc.GetAliasWithContext(ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(funcName),
+		Name:         aws.String(alias),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(res.FunctionVersion), nil
+}
+
+// routeAlias keeps the alias on its live version while sending weight of its invocations to the canary version
+func (p *Provisioner) routeAlias(ctx context.Context, funcName, alias, live, canary string, weight float64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.LambdaSvc.UpdateAliasWithContext(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(funcName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(live),
+		RoutingConfig: &lambda.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]*float64{canary: aws.Float64(weight)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("routing alias %s of function %s to version %s: %w", alias, funcName, canary, err)
+	}
+	return nil
+}
+
+// watchCanary reads the errors of the canary version every canaryCheckInterval until c.Interval has passed,
+// it returns ErrCanaryFailed as soon as any are reported
+func (p *Provisioner) watchCanary(ctx context.Context, funcName string, c Canary, version string) error {
+	start := time.Now()
+	return p.wait(func() error {
+		for {
+			next := canaryCheckInterval
+			if remaining := c.Interval - time.Since(start); remaining < next {
+				next = remaining
+			}
+			if next > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(next):
+				}
+			}
+
+			errs, err := p.versionErrors(ctx, funcName, c.Alias, version, start)
+			if err != nil {
+				return fmt.Errorf("reading the errors of version %s: %w", version, err)
+			}
+			if errs > 0 {
+				return fmt.Errorf("%w: version %s of function %s failed %v invocations", ErrCanaryFailed, version, funcName, errs)
+			}
+			if time.Since(start) >= c.Interval {
+				return nil
+			}
+		}
+	})
+}
+
+// versionErrors sums the Errors metric of the invocations of a version through an alias since the given time
+func (p *Provisioner) versionErrors(ctx context.Context, funcName, alias, version string, since time.Time) (float64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.CloudWatchSvc.GetMetricStatisticsWithContext(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/Lambda"),
+		MetricName: aws.String("Errors"),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("FunctionName"), Value: aws.String(funcName)},
+			{Name: aws.String("Resource"), Value: aws.String(funcName + ":" + alias)},
+			{Name: aws.String("ExecutedVersion"), Value: aws.String(version)},
+		},
+		StartTime:  aws.Time(since.Truncate(time.Minute)),
+		EndTime:    aws.Time(time.Now()),
+		Period:     aws.Int64(60),
+		Statistics: aws.StringSlice([]string{cloudwatch.StatisticSum}),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for _, point := range res.Datapoints {
+		sum += aws.Float64Value(point.Sum)
+	}
+	return sum, nil
+}
+
+// qualified returns the function name with the alias appended, which Lambda accepts wherever a function name is
+// expected to act on the alias instead of the unqualified function
+func qualified(funcName, alias string) string {
+	if alias == "" {
+		return funcName
+	}
+	return funcName + ":" + alias
+}
+
+// baseName strips the alias or version from a qualified function name
+func baseName(funcName string) string {
+	if i := strings.Index(funcName, ":"); i >= 0 {
+		return funcName[:i]
+	}
+	return funcName
+}
@@ -0,0 +1,94 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestDeployLambda(t *testing.T) {
+	defer func(d time.Duration) { canaryCheckInterval = d }(canaryCheckInterval)
+	canaryCheckInterval = time.Millisecond
+
+	p, _, fl, _ := newFakeProvisioner()
+	fc := p.CloudWatchSvc.(*fakeCloudWatch)
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), Handler: aws.String("main"), Runtime: aws.String("go1.x"),
+	}
+	code, _ := writePackage(t, "main")
+	l := Lambda{FunctionName: "stack-action", Code: code}
+	c := Canary{Alias: "live", Weight: 0.1, Interval: 5 * time.Millisecond}
+
+	if _, err := p.DeployLambda(ctx, l, c); err != nil {
+		t.Fatalf("DeployLambda failed: %v", err)
+	}
+	if fl.aliases["stack-action:live"] != "1" || len(fl.routed) != 0 {
+		t.Errorf("DeployLambda failed, expected a new alias on version 1 without a canary, got %v routed %v",
+			fl.aliases["stack-action:live"], fl.routed)
+	}
+
+	if _, err := p.DeployLambda(ctx, l, c); err != nil {
+		t.Fatalf("DeployLambda failed: %v", err)
+	}
+	if len(fl.routed) != 1 || aws.Float64Value(fl.routed[0]["2"]) != 0.1 {
+		t.Errorf("DeployLambda failed, expected 10%% routed to version 2, got %v", fl.routed)
+	}
+	if fc.checks == 0 {
+		t.Errorf("DeployLambda failed, expected the errors of the canary to be checked")
+	}
+	if fl.aliases["stack-action:live"] != "2" || len(fl.routing["stack-action:live"]) != 0 {
+		t.Errorf("DeployLambda failed, expected alias live promoted to version 2, got %v routing %v",
+			fl.aliases["stack-action:live"], fl.routing["stack-action:live"])
+	}
+
+	fc.errors["3"] = 2
+	if _, err := p.DeployLambda(ctx, l, c); !errors.Is(err, ErrCanaryFailed) {
+		t.Errorf("DeployLambda failed, expected %v, got %v", ErrCanaryFailed, err)
+	}
+	if fl.aliases["stack-action:live"] != "2" || len(fl.routing["stack-action:live"]) != 0 {
+		t.Errorf("DeployLambda failed, expected alias live rolled back to version 2, got %v routing %v",
+			fl.aliases["stack-action:live"], fl.routing["stack-action:live"])
+	}
+
+	fc.errors["3"] = 0
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err := p.DeployLambda(cctx, l, Canary{Alias: "live", Weight: 0.1, Interval: time.Hour})
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCanaryFailed) {
+		t.Errorf("DeployLambda failed, expected %v for a deploy cut short, got %v", context.DeadlineExceeded, err)
+	}
+	if fl.aliases["stack-action:live"] != "2" || len(fl.routing["stack-action:live"]) != 0 {
+		t.Errorf("DeployLambda failed, expected alias live rolled back to version 2 once cut short, got %v routing %v",
+			fl.aliases["stack-action:live"], fl.routing["stack-action:live"])
+	}
+
+	if _, err := p.DeployLambda(ctx, l, Canary{Alias: "live", Weight: 1}); err == nil {
+		t.Errorf("DeployLambda expected an error for a weight of 1")
+	}
+}
+
+func TestSetupGatewayOnAlias(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action"),
+	}
+	if err := p.publishAlias(ctx, "stack-action", "live"); err != nil {
+		t.Fatalf("publishAlias failed: %v", err)
+	}
+
+	if _, err := p.SetupGateway(ctx, Gateway{Name: "stack-api", FunctionName: "stack-action", Alias: "live"}); err != nil {
+		t.Fatalf("SetupGateway failed: %v", err)
+	}
+	if len(fl.permissions["stack-action:live"]) != len(gatewayStatementIDs) || len(fl.permissions["stack-action"]) != 0 {
+		t.Errorf("SetupGateway failed, expected the permissions on alias live, got %v", fl.permissions)
+	}
+	if _, err := p.RemoveLambdaPermissions(ctx, qualified("stack-action", "live")); err != nil || len(fl.permissions["stack-action:live"]) != 0 {
+		t.Errorf("RemoveLambdaPermissions failed, expected the alias permissions removed, got %v, %v", fl.permissions, err)
+	}
+}
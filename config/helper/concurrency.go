@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Concurrency is the concurrency configured on a function; Reserved is nil when the function draws on the
+// account's unreserved pool, Provisioned lists the provisioned concurrency of each alias or version
+type Concurrency struct {
+	Reserved    *int64
+	Provisioned []*lambda.ProvisionedConcurrencyConfigListItem
+}
+
+// FunctionConcurrency returns the reserved and provisioned concurrency of the function
+func (p *Provisioner) FunctionConcurrency(ctx context.Context, funcName string) (*Concurrency, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.LambdaSvc.GetFunctionConcurrencyWithContext(ctx, &lambda.GetFunctionConcurrencyInput{
+		FunctionName: aws.String(funcName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking up the concurrency of function %s: %w", funcName, err)
+	}
+	c := &Concurrency{Reserved: res.ReservedConcurrentExecutions}
+
+	err = p.LambdaSvc.ListProvisionedConcurrencyConfigsPagesWithContext(ctx, &lambda.ListProvisionedConcurrencyConfigsInput{
+		FunctionName: aws.String(funcName),
+	}, func(page *lambda.ListProvisionedConcurrencyConfigsOutput, lastPage bool) bool {
+		c.Provisioned = append(c.Provisioned, page.ProvisionedConcurrencyConfigs...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing the provisioned concurrency of function %s: %w", funcName, err)
+	}
+	return c, nil
+}
+
+// SetReservedConcurrency reserves n concurrent executions for the function, which also caps it at n; a
+// reservation of 0 throttles every invocation. A nil n removes the reservation
+func (p *Provisioner) SetReservedConcurrency(ctx context.Context, funcName string, n *int64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if n == nil {
+		_, err = p.LambdaSvc.DeleteFunctionConcurrencyWithContext(ctx, &lambda.DeleteFunctionConcurrencyInput{
+			FunctionName: aws.String(funcName),
+		})
+	} else {
+		_, err = p.LambdaSvc.PutFunctionConcurrencyWithContext(ctx, &lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 aws.String(funcName),
+			ReservedConcurrentExecutions: n,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("setting the reserved concurrency of function %s: %w", funcName, err)
+	}
+	return nil
+}
+
+// SetProvisionedConcurrency keeps n execution environments of the alias or version initialised, and waits until
+// they are ready. An n of 0 removes the provisioned concurrency
+func (p *Provisioner) SetProvisionedConcurrency(ctx context.Context, funcName, qualifier string, n int64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if n == 0 {
+		_, err := p.LambdaSvc.DeleteProvisionedConcurrencyConfigWithContext(ctx, &lambda.DeleteProvisionedConcurrencyConfigInput{
+			FunctionName: aws.String(funcName),
+			Qualifier:    aws.String(qualifier),
+		})
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("removing the provisioned concurrency of %s: %w", qualified(funcName, qualifier), err)
+		}
+		return nil
+	}
+
+	_, err := p.LambdaSvc.PutProvisionedConcurrencyConfigWithContext(ctx, &lambda.PutProvisionedConcurrencyConfigInput{
+		FunctionName:                    aws.String(funcName),
+		Qualifier:                       aws.String(qualifier),
+		ProvisionedConcurrentExecutions: aws.Int64(n),
+	})
+	if err != nil {
+		return fmt.Errorf("provisioning concurrency for %s: %w", qualified(funcName, qualifier), err)
+	}
+
+	return p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			res, err := p.LambdaSvc.GetProvisionedConcurrencyConfigWithContext(ctx, &lambda.GetProvisionedConcurrencyConfigInput{
+				FunctionName: aws.String(funcName),
+				Qualifier:    aws.String(qualifier),
+			})
+			if err != nil {
+				return false, fmt.Errorf("waiting for the provisioned concurrency of %s: %w", qualified(funcName, qualifier), err)
+			}
+			switch aws.StringValue(res.Status) {
+			case lambda.ProvisionedConcurrencyStatusEnumReady:
+				return true, nil
+			case lambda.ProvisionedConcurrencyStatusEnumFailed:
+				return false, fmt.Errorf("provisioning concurrency for %s failed: %s", qualified(funcName, qualifier), aws.StringValue(res.StatusReason))
+			}
+			return false, nil
+		})
+	})
+}
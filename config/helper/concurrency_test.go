@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestConcurrency(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), Handler: aws.String("main"), Runtime: aws.String("go1.x"),
+	}
+
+	l := Lambda{FunctionName: "stack-action", ReservedConcurrency: aws.Int64(0), ProvisionedConcurrency: 2}
+	if _, err := p.UpdateLambda(ctx, l, false, ""); err == nil {
+		t.Errorf("UpdateLambda expected an error for provisioned concurrency without an alias")
+	}
+	if _, err := p.UpdateLambda(ctx, l, false, "live"); err != nil {
+		t.Fatalf("UpdateLambda failed: %v", err)
+	}
+
+	c, err := p.FunctionConcurrency(ctx, "stack-action")
+	if err != nil {
+		t.Fatalf("FunctionConcurrency failed: %v", err)
+	}
+	if c.Reserved == nil || *c.Reserved != 0 {
+		t.Errorf("FunctionConcurrency failed, expected 0 reserved, got %v", c.Reserved)
+	}
+	if len(c.Provisioned) != 1 || aws.Int64Value(c.Provisioned[0].RequestedProvisionedConcurrentExecutions) != 2 {
+		t.Errorf("FunctionConcurrency failed, expected 2 provisioned on live, got %v", c.Provisioned)
+	}
+
+	if err := p.SetReservedConcurrency(ctx, "stack-action", nil); err != nil {
+		t.Fatalf("SetReservedConcurrency failed: %v", err)
+	}
+	if err := p.SetProvisionedConcurrency(ctx, "stack-action", "live", 0); err != nil {
+		t.Fatalf("SetProvisionedConcurrency failed: %v", err)
+	}
+	if c, _ = p.FunctionConcurrency(ctx, "stack-action"); c.Reserved != nil || len(c.Provisioned) != 0 {
+		t.Errorf("FunctionConcurrency failed, expected no concurrency once removed, got %v and %v", c.Reserved, c.Provisioned)
+	}
+	if err := p.SetProvisionedConcurrency(ctx, "stack-action", "live", 0); err != nil {
+		t.Errorf("SetProvisionedConcurrency failed, expected removing it twice to succeed, got %v", err)
+	}
+}
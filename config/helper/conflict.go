@@ -0,0 +1,185 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// ConflictMode decides what a create does when the resource already exists
+type ConflictMode string
+
+const (
+	// ConflictFail returns an error, this is the default
+	ConflictFail ConflictMode = "fail"
+	// ConflictAdopt uses the existing resource as it is
+	ConflictAdopt ConflictMode = "adopt"
+	// ConflictUpdate changes the existing resource to match the requested configuration
+	ConflictUpdate ConflictMode = "update"
+)
+
+// ErrAlreadyExists is returned by creates in ConflictFail mode when the resource already exists
+var ErrAlreadyExists = errors.New("already exists")
+
+// ParseConflictMode returns the ConflictMode named by s, one of adopt, update or fail
+func ParseConflictMode(s string) (ConflictMode, error) {
+	switch m := ConflictMode(s); m {
+	case ConflictFail, ConflictAdopt, ConflictUpdate:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown conflict mode %q, use adopt, update or fail", s)
+}
+
+// isAlreadyExists reports whether err is the already exists error of IAM or Lambda
+func isAlreadyExists(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	switch aerr.Code() {
+	case iam.ErrCodeEntityAlreadyExistsException, lambda.ErrCodeResourceConflictException:
+		return true
+	}
+	return false
+}
+
+// existingRole handles CreateRole finding a role of the same name according to the Provisioner's OnConflict
+func (p *Provisioner) existingRole(ctx context.Context, args Role, err error) (*iam.Role, error) {
+	switch p.OnConflict {
+	case ConflictAdopt:
+		fmt.Println("Adopting existing role: ", args.RoleName)
+	case ConflictUpdate:
+		fmt.Println("Updating existing role: ", args.RoleName)
+		_, err := p.IAMSvc.UpdateAssumeRolePolicyWithContext(ctx, &iam.UpdateAssumeRolePolicyInput{
+			RoleName:       aws.String(args.RoleName),
+			PolicyDocument: aws.String(assumeRolePolicy(args.Service)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating trust policy of role %s: %w", args.RoleName, err)
+		}
+		_, err = p.IAMSvc.UpdateRoleWithContext(ctx, &iam.UpdateRoleInput{
+			RoleName:    aws.String(args.RoleName),
+			Description: aws.String(args.Description),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating description of role %s: %w", args.RoleName, err)
+		}
+	default:
+		return nil, fmt.Errorf("role %s %w, use --on-conflict=adopt or update to reuse it: %v", args.RoleName, ErrAlreadyExists, err)
+	}
+
+	res, err := p.IAMSvc.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: aws.String(args.RoleName)})
+	if err != nil {
+		return nil, err
+	}
+	return res.Role, nil
+}
+
+// existingFunction handles CreateLambda finding a function of the same name according to the Provisioner's
+// OnConflict
+func (p *Provisioner) existingFunction(ctx context.Context, l Lambda, code *lambda.FunctionCode, err error) (*lambda.FunctionConfiguration, error) {
+	switch p.OnConflict {
+	case ConflictAdopt:
+		fmt.Println("Adopting existing function: ", l.FunctionName)
+		return p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(l.FunctionName),
+		})
+	case ConflictUpdate:
+		fmt.Println("Updating existing function: ", l.FunctionName)
+		return p.updateFunction(ctx, l, code, false)
+	}
+	return nil, fmt.Errorf("function %s %w, use --on-conflict=adopt or update to reuse it: %v", l.FunctionName, ErrAlreadyExists, err)
+}
+
+// updateFunction changes the configuration and then the code of an existing function to match l, waiting
+// for each update to finish as Lambda rejects a second update while the first is in progress. Only the fields
+// of l which are set are changed and the code is left alone when code is nil. With publish a version of the
+// result is published
+func (p *Provisioner) updateFunction(ctx context.Context, l Lambda, code *lambda.FunctionCode, publish bool) (*lambda.FunctionConfiguration, error) {
+	name := aws.String(l.FunctionName)
+	wait := func() error {
+		return p.wait(func() error {
+			return p.LambdaSvc.WaitUntilFunctionUpdatedWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+				FunctionName: name,
+			}, waiterOptions()...)
+		})
+	}
+
+	var (
+		res *lambda.FunctionConfiguration
+		err error
+	)
+	if cfg := l.configuration(); cfg != nil {
+		res, err = p.LambdaSvc.UpdateFunctionConfigurationWithContext(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("updating configuration of function %s: %w", l.FunctionName, err)
+		}
+		if err := wait(); err != nil {
+			return nil, fmt.Errorf("waiting for configuration of function %s to update: %w", l.FunctionName, err)
+		}
+	}
+
+	if code != nil {
+		res, err = p.LambdaSvc.UpdateFunctionCodeWithContext(ctx, &lambda.UpdateFunctionCodeInput{
+			FunctionName:    name,
+			ZipFile:         code.ZipFile,
+			S3Bucket:        code.S3Bucket,
+			S3Key:           code.S3Key,
+			S3ObjectVersion: code.S3ObjectVersion,
+			Publish:         aws.Bool(publish),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating code of function %s: %w", l.FunctionName, err)
+		}
+		if err := wait(); err != nil {
+			return nil, fmt.Errorf("waiting for code of function %s to update: %w", l.FunctionName, err)
+		}
+	} else if publish {
+		res, err = p.LambdaSvc.PublishVersionWithContext(ctx, &lambda.PublishVersionInput{
+			FunctionName: name,
+			Description:  optional(l.Description),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("publishing a version of function %s: %w", l.FunctionName, err)
+		}
+	}
+
+	if res == nil {
+		if res, err = p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{FunctionName: name}); err != nil {
+			return nil, err
+		}
+	}
+	if len(l.Tags) > 0 {
+		_, err = p.LambdaSvc.TagResourceWithContext(ctx, &lambda.TagResourceInput{
+			Resource: aws.String(unqualified(aws.StringValue(res.FunctionArn))),
+			Tags:     aws.StringMap(l.Tags),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tagging function %s: %w", l.FunctionName, err)
+		}
+	}
+	return res, nil
+}
+
+// unqualified strips the version or alias from a function ARN, as tags apply to the function as a whole
+func unqualified(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) > 7 {
+		parts = parts[:7]
+	}
+	return strings.Join(parts, ":")
+}
+
+// optional returns nil for an empty string, so unset fields are left out of a request rather than cleared
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
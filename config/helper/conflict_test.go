@@ -0,0 +1,103 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestCreateRoleOnConflict(t *testing.T) {
+	ctx := context.Background()
+	first := Role{RoleName: "Testing1", Description: "first", Service: "ec2.amazonaws.com"}
+	second := Role{RoleName: "Testing1", Description: "second", Service: "lambda.amazonaws.com"}
+
+	tests := []struct {
+		mode        ConflictMode
+		wantErr     error
+		wantDesc    string
+		wantService string
+	}{
+		{ConflictFail, ErrAlreadyExists, "first", "ec2.amazonaws.com"},
+		{ConflictAdopt, nil, "first", "ec2.amazonaws.com"},
+		{ConflictUpdate, nil, "second", "lambda.amazonaws.com"},
+	}
+	for _, tt := range tests {
+		p, i, _, _ := newFakeProvisioner()
+		p.OnConflict = tt.mode
+		if _, err := p.CreateRole(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+
+		role, err := p.CreateRole(ctx, second)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("CreateRole with %s failed, expected error %v, got %v", tt.mode, tt.wantErr, err)
+		}
+		if err == nil && aws.StringValue(role.Arn) == "" {
+			t.Errorf("CreateRole with %s failed, expected the existing role, got %v", tt.mode, role)
+		}
+
+		got := i.roles["Testing1"]
+		doc, _ := url.QueryUnescape(aws.StringValue(got.AssumeRolePolicyDocument))
+		if aws.StringValue(got.Description) != tt.wantDesc || doc != assumeRolePolicy(tt.wantService) {
+			t.Errorf("CreateRole with %s failed, expected description %q and service %q, got %q and %q",
+				tt.mode, tt.wantDesc, tt.wantService, aws.StringValue(got.Description), doc)
+		}
+	}
+}
+
+func TestCreateLambdaOnConflict(t *testing.T) {
+	code, pkg := writePackage(t, "main")
+	ctx := context.Background()
+	l := Lambda{
+		FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/r", Code: code,
+		ReservedConcurrency: aws.Int64(5), MaximumRetryAttempts: aws.Int64(1),
+	}
+
+	tests := []struct {
+		mode        ConflictMode
+		wantErr     error
+		wantHandler string
+		wantCode    string
+		wantApplied bool
+	}{
+		{ConflictFail, ErrAlreadyExists, "old", "old code", false},
+		{ConflictAdopt, nil, "old", "old code", false},
+		{ConflictUpdate, nil, "main", string(pkg), true},
+	}
+	for _, tt := range tests {
+		p, _, fl, _ := newFakeProvisioner()
+		p.OnConflict = tt.mode
+		fl.functions["stack-action"] = &lambda.FunctionConfiguration{FunctionName: aws.String("stack-action"), Handler: aws.String("old")}
+		fl.code["stack-action"] = []byte("old code")
+
+		_, err := p.CreateLambda(ctx, l)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("CreateLambda with %s failed, expected error %v, got %v", tt.mode, tt.wantErr, err)
+		}
+		if got := aws.StringValue(fl.functions["stack-action"].Handler); got != tt.wantHandler {
+			t.Errorf("CreateLambda with %s failed, expected handler %q, got %q", tt.mode, tt.wantHandler, got)
+		}
+		if got := string(fl.code["stack-action"]); got != tt.wantCode {
+			t.Errorf("CreateLambda with %s failed, expected code %q, got %q", tt.mode, tt.wantCode, got)
+		}
+		if _, got := fl.reserved["stack-action"]; got != tt.wantApplied {
+			t.Errorf("CreateLambda with %s failed, expected reserved concurrency set %v, got %v", tt.mode, tt.wantApplied, fl.reserved)
+		}
+		if _, got := fl.invokeConfigs["stack-action"]; got != tt.wantApplied {
+			t.Errorf("CreateLambda with %s failed, expected the asynchronous invocation config set %v, got %v", tt.mode, tt.wantApplied, fl.invokeConfigs)
+		}
+	}
+}
+
+func TestParseConflictMode(t *testing.T) {
+	if m, err := ParseConflictMode("update"); err != nil || m != ConflictUpdate {
+		t.Errorf("ParseConflictMode(%q) failed, got %v %v", "update", m, err)
+	}
+	if _, err := ParseConflictMode("replace"); err == nil {
+		t.Errorf("ParseConflictMode(%q) expected an error", "replace")
+	}
+}
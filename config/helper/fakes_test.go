@@ -0,0 +1,1173 @@
+package helper
+
+import (
+	"debug/elf"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/deploy"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/apigateway/apigatewayiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// writePackage writes a deployment package which passes deploy.Validate for the go1.x handler, holding just the
+// ELF header of a linux/amd64 binary, and returns its path and contents
+func writePackage(t *testing.T, handler string) (string, []byte) {
+	bin := make([]byte, 64)
+	copy(bin, elf.ELFMAG)
+	bin[elf.EI_CLASS], bin[elf.EI_DATA], bin[elf.EI_VERSION] = byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)
+	binary.LittleEndian.PutUint16(bin[16:], uint16(elf.ET_EXEC))
+	binary.LittleEndian.PutUint16(bin[18:], uint16(elf.EM_X86_64))
+	binary.LittleEndian.PutUint32(bin[20:], uint32(elf.EV_CURRENT))
+
+	pkg, err := deploy.Zip(deploy.File{Name: handler, Mode: 0755, Body: bin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeManifest(t, "deployment.zip", string(pkg)), pkg
+}
+
+// The fakes below keep just enough in-memory state to behave like the real services for the calls the
+// Provisioner makes, any method not overridden here panics through the nil embedded interface
+
+type fakeIAM struct {
+	iamiface.IAMAPI
+	roles    map[string]*iam.Role
+	attached map[string][]string
+	deleted  []string
+}
+
+func newFakeIAM() *fakeIAM {
+	return &fakeIAM{roles: map[string]*iam.Role{}, attached: map[string][]string{}}
+}
+
+func (f *fakeIAM) CreateRoleWithContext(ctx aws.Context, in *iam.CreateRoleInput, opts ...request.Option) (*iam.CreateRoleOutput, error) {
+	name := aws.StringValue(in.RoleName)
+	if _, ok := f.roles[name]; ok {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, "Role with name "+name+" already exists.", nil)
+	}
+	f.roles[name] = &iam.Role{
+		RoleName:                 in.RoleName,
+		Arn:                      aws.String("arn:aws:iam::123456789012:role/" + name),
+		AssumeRolePolicyDocument: aws.String(url.QueryEscape(aws.StringValue(in.AssumeRolePolicyDocument))),
+		Description:              in.Description,
+	}
+	return &iam.CreateRoleOutput{Role: f.roles[name]}, nil
+}
+
+func (f *fakeIAM) WaitUntilRoleExistsWithContext(ctx aws.Context, in *iam.GetRoleInput, opts ...request.WaiterOption) error {
+	_, err := f.GetRoleWithContext(ctx, in)
+	return err
+}
+
+func (f *fakeIAM) GetRoleWithContext(ctx aws.Context, in *iam.GetRoleInput, opts ...request.Option) (*iam.GetRoleOutput, error) {
+	role, ok := f.roles[aws.StringValue(in.RoleName)]
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "The role cannot be found.", nil)
+	}
+	return &iam.GetRoleOutput{Role: role}, nil
+}
+
+func (f *fakeIAM) DeleteRoleWithContext(ctx aws.Context, in *iam.DeleteRoleInput, opts ...request.Option) (*iam.DeleteRoleOutput, error) {
+	name := aws.StringValue(in.RoleName)
+	if _, ok := f.roles[name]; !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "The role cannot be found.", nil)
+	}
+	if len(f.attached[name]) > 0 {
+		return nil, awserr.New(iam.ErrCodeDeleteConflictException, "Cannot delete entity, must detach all policies first.", nil)
+	}
+	delete(f.roles, name)
+	f.deleted = append(f.deleted, name)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func (f *fakeIAM) UpdateAssumeRolePolicyWithContext(ctx aws.Context, in *iam.UpdateAssumeRolePolicyInput, opts ...request.Option) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	role, ok := f.roles[aws.StringValue(in.RoleName)]
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "The role cannot be found.", nil)
+	}
+	role.AssumeRolePolicyDocument = aws.String(url.QueryEscape(aws.StringValue(in.PolicyDocument)))
+	return &iam.UpdateAssumeRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) UpdateRoleWithContext(ctx aws.Context, in *iam.UpdateRoleInput, opts ...request.Option) (*iam.UpdateRoleOutput, error) {
+	role, ok := f.roles[aws.StringValue(in.RoleName)]
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "The role cannot be found.", nil)
+	}
+	role.Description = in.Description
+	return &iam.UpdateRoleOutput{}, nil
+}
+
+func (f *fakeIAM) AttachRolePolicyWithContext(ctx aws.Context, in *iam.AttachRolePolicyInput, opts ...request.Option) (*iam.AttachRolePolicyOutput, error) {
+	name := aws.StringValue(in.RoleName)
+	if _, ok := f.roles[name]; !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "The role cannot be found.", nil)
+	}
+	// attaching a policy which is already attached changes nothing
+	if !contains(f.attached[name], aws.StringValue(in.PolicyArn)) {
+		f.attached[name] = append(f.attached[name], aws.StringValue(in.PolicyArn))
+	}
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) DetachRolePolicyWithContext(ctx aws.Context, in *iam.DetachRolePolicyInput, opts ...request.Option) (*iam.DetachRolePolicyOutput, error) {
+	name, arn := aws.StringValue(in.RoleName), aws.StringValue(in.PolicyArn)
+	for i, attached := range f.attached[name] {
+		if attached == arn {
+			f.attached[name] = append(f.attached[name][:i], f.attached[name][i+1:]...)
+			return &iam.DetachRolePolicyOutput{}, nil
+		}
+	}
+	return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "Policy "+arn+" was not found.", nil)
+}
+
+func (f *fakeIAM) ListAttachedRolePoliciesPagesWithContext(ctx aws.Context, in *iam.ListAttachedRolePoliciesInput, fn func(*iam.ListAttachedRolePoliciesOutput, bool) bool, opts ...request.Option) error {
+	var page iam.ListAttachedRolePoliciesOutput
+	for _, arn := range f.attached[aws.StringValue(in.RoleName)] {
+		page.AttachedPolicies = append(page.AttachedPolicies, &iam.AttachedPolicy{PolicyArn: aws.String(arn)})
+	}
+	fn(&page, true)
+	return nil
+}
+
+type fakeLambda struct {
+	lambdaiface.LambdaAPI
+	functions   map[string]*lambda.FunctionConfiguration
+	code        map[string][]byte
+	permissions map[string][]string
+	// sources is the source ARN of each permission, keyed by function/statement ID
+	sources map[string]string
+	// versions counts the versions published of each function, aliases maps function:alias to a version
+	versions map[string]int
+	aliases  map[string]string
+	// routed holds every version weight an alias was given, routing the alias' current weights
+	routed  []map[string]*float64
+	routing map[string]map[string]*float64
+	// reserved is the reserved concurrency of each function, provisioned that of each function:qualifier
+	reserved    map[string]int64
+	provisioned map[string]int64
+	// invokeConfigs is the asynchronous invocation config of each function
+	invokeConfigs map[string]*lambda.GetFunctionEventInvokeConfigOutput
+	// layers holds the versions published of each layer, oldest first, layerCode the package of each version ARN
+	layers    map[string][]*lambda.LayerVersionsListItem
+	layerCode map[string][]byte
+	// mappings are the event source mappings by UUID, enabled whether each is enabled once created, and
+	// unreadable how many more times creating a mapping fails as the role cannot read the source yet
+	mappings   map[string]*lambda.EventSourceMappingConfiguration
+	enabled    map[string]bool
+	unreadable int
+	// noENIAccess is how many more times creating a function in a VPC fails as the role cannot create its
+	// network interfaces yet
+	noENIAccess int
+	tags        map[string]map[string]*string
+	// failPermission is returned by AddPermission once the function has this many permissions
+	failPermission error
+	failAfter      int
+	// invokeDeadline is how long the last invocation had left before its context's deadline
+	invokeDeadline time.Duration
+}
+
+func newFakeLambda() *fakeLambda {
+	return &fakeLambda{
+		functions:   map[string]*lambda.FunctionConfiguration{},
+		code:        map[string][]byte{},
+		permissions: map[string][]string{},
+		sources:     map[string]string{},
+		versions:    map[string]int{},
+		aliases:     map[string]string{},
+		routing:     map[string]map[string]*float64{},
+		reserved:    map[string]int64{},
+		provisioned: map[string]int64{},
+
+		invokeConfigs: map[string]*lambda.GetFunctionEventInvokeConfigOutput{},
+		layers:        map[string][]*lambda.LayerVersionsListItem{},
+		layerCode:     map[string][]byte{},
+		mappings:      map[string]*lambda.EventSourceMappingConfiguration{},
+		enabled:       map[string]bool{},
+		tags:          map[string]map[string]*string{},
+	}
+}
+
+// publish returns a copy of the function configuration as a newly published version
+func (f *fakeLambda) publish(name string) *lambda.FunctionConfiguration {
+	f.versions[name]++
+	v := *f.functions[name]
+	v.Version = aws.String(fmt.Sprint(f.versions[name]))
+	return &v
+}
+
+// functionLayers returns the layers a function is configured with from the ARNs it was given
+func functionLayers(arns []*string) []*lambda.Layer {
+	var layers []*lambda.Layer
+	for _, arn := range arns {
+		layers = append(layers, &lambda.Layer{Arn: arn})
+	}
+	return layers
+}
+
+// codeOf returns the package a function was given, or its S3 location when it was staged
+func codeOf(zip []byte, bucket, key *string) []byte {
+	if bucket != nil {
+		return []byte("s3://" + aws.StringValue(bucket) + "/" + aws.StringValue(key))
+	}
+	return zip
+}
+
+func (f *fakeLambda) notFound(name string) error {
+	return awserr.New(lambda.ErrCodeResourceNotFoundException, "Function not found: "+name, nil)
+}
+
+func (f *fakeLambda) CreateFunctionWithContext(ctx aws.Context, in *lambda.CreateFunctionInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[name]; ok {
+		return nil, awserr.New(lambda.ErrCodeResourceConflictException, "Function already exist: "+name, nil)
+	}
+	if in.VpcConfig != nil && f.noENIAccess > 0 {
+		f.noENIAccess--
+		return nil, awserr.New(lambda.ErrCodeInvalidParameterValueException,
+			"The provided execution role does not have permissions to call CreateNetworkInterface on EC2", nil)
+	}
+	f.code[name] = codeOf(in.Code.ZipFile, in.Code.S3Bucket, in.Code.S3Key)
+	f.functions[name] = &lambda.FunctionConfiguration{
+		FunctionName: in.FunctionName,
+		FunctionArn:  aws.String("arn:aws:lambda:eu-west-2:123456789012:function:" + name),
+		Handler:      in.Handler,
+		Role:         in.Role,
+		Runtime:      in.Runtime,
+		Description:  in.Description,
+		State:        aws.String(lambda.StateActive),
+		MemorySize:   in.MemorySize,
+		Timeout:      in.Timeout,
+		KMSKeyArn:    in.KMSKeyArn,
+		Layers:       functionLayers(in.Layers),
+	}
+	if in.VpcConfig != nil {
+		f.functions[name].VpcConfig = &lambda.VpcConfigResponse{SubnetIds: in.VpcConfig.SubnetIds, SecurityGroupIds: in.VpcConfig.SecurityGroupIds}
+	}
+	if in.Environment != nil {
+		f.functions[name].Environment = &lambda.EnvironmentResponse{Variables: in.Environment.Variables}
+	}
+	f.tags[name] = in.Tags
+	return f.functions[name], nil
+}
+
+func (f *fakeLambda) WaitUntilFunctionActiveWithContext(ctx aws.Context, in *lambda.GetFunctionConfigurationInput, opts ...request.WaiterOption) error {
+	_, err := f.GetFunctionConfigurationWithContext(ctx, in)
+	return err
+}
+
+func (f *fakeLambda) WaitUntilFunctionUpdatedWithContext(ctx aws.Context, in *lambda.GetFunctionConfigurationInput, opts ...request.WaiterOption) error {
+	_, err := f.GetFunctionConfigurationWithContext(ctx, in)
+	return err
+}
+
+func (f *fakeLambda) UpdateFunctionConfigurationWithContext(ctx aws.Context, in *lambda.UpdateFunctionConfigurationInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	fn, ok := f.functions[aws.StringValue(in.FunctionName)]
+	if !ok {
+		return nil, f.notFound(aws.StringValue(in.FunctionName))
+	}
+	if in.Description != nil {
+		fn.Description = in.Description
+	}
+	if in.Handler != nil {
+		fn.Handler = in.Handler
+	}
+	if in.Role != nil {
+		fn.Role = in.Role
+	}
+	if in.Runtime != nil {
+		fn.Runtime = in.Runtime
+	}
+	if in.MemorySize != nil {
+		fn.MemorySize = in.MemorySize
+	}
+	if in.Timeout != nil {
+		fn.Timeout = in.Timeout
+	}
+	if in.Environment != nil {
+		fn.Environment = &lambda.EnvironmentResponse{Variables: in.Environment.Variables}
+	}
+	if in.Layers != nil {
+		fn.Layers = functionLayers(in.Layers)
+	}
+	return fn, nil
+}
+
+func (f *fakeLambda) TagResourceWithContext(ctx aws.Context, in *lambda.TagResourceInput, opts ...request.Option) (*lambda.TagResourceOutput, error) {
+	arn := aws.StringValue(in.Resource)
+	name := arn[strings.LastIndex(arn, ":")+1:]
+	if _, ok := f.functions[name]; !ok {
+		return nil, f.notFound(arn)
+	}
+	if f.tags[name] == nil {
+		f.tags[name] = map[string]*string{}
+	}
+	for k, v := range in.Tags {
+		f.tags[name][k] = v
+	}
+	return &lambda.TagResourceOutput{}, nil
+}
+
+func (f *fakeLambda) UpdateFunctionCodeWithContext(ctx aws.Context, in *lambda.UpdateFunctionCodeInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	name := aws.StringValue(in.FunctionName)
+	fn, ok := f.functions[name]
+	if !ok {
+		return nil, f.notFound(name)
+	}
+	f.code[name] = codeOf(in.ZipFile, in.S3Bucket, in.S3Key)
+	if aws.BoolValue(in.Publish) {
+		return f.publish(name), nil
+	}
+	return fn, nil
+}
+
+func (f *fakeLambda) PublishVersionWithContext(ctx aws.Context, in *lambda.PublishVersionInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[name]; !ok {
+		return nil, f.notFound(name)
+	}
+	return f.publish(name), nil
+}
+
+func (f *fakeLambda) UpdateAliasWithContext(ctx aws.Context, in *lambda.UpdateAliasInput, opts ...request.Option) (*lambda.AliasConfiguration, error) {
+	key := aws.StringValue(in.FunctionName) + ":" + aws.StringValue(in.Name)
+	if _, ok := f.aliases[key]; !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "Alias not found: "+key, nil)
+	}
+	f.aliases[key] = aws.StringValue(in.FunctionVersion)
+	if in.RoutingConfig != nil {
+		f.routing[key] = in.RoutingConfig.AdditionalVersionWeights
+		if len(in.RoutingConfig.AdditionalVersionWeights) > 0 {
+			f.routed = append(f.routed, in.RoutingConfig.AdditionalVersionWeights)
+		}
+	}
+	return &lambda.AliasConfiguration{Name: in.Name, FunctionVersion: in.FunctionVersion}, nil
+}
+
+func (f *fakeLambda) PutFunctionConcurrencyWithContext(ctx aws.Context, in *lambda.PutFunctionConcurrencyInput, opts ...request.Option) (*lambda.PutFunctionConcurrencyOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[name]; !ok {
+		return nil, f.notFound(name)
+	}
+	f.reserved[name] = aws.Int64Value(in.ReservedConcurrentExecutions)
+	return &lambda.PutFunctionConcurrencyOutput{ReservedConcurrentExecutions: in.ReservedConcurrentExecutions}, nil
+}
+
+func (f *fakeLambda) DeleteFunctionConcurrencyWithContext(ctx aws.Context, in *lambda.DeleteFunctionConcurrencyInput, opts ...request.Option) (*lambda.DeleteFunctionConcurrencyOutput, error) {
+	delete(f.reserved, aws.StringValue(in.FunctionName))
+	return &lambda.DeleteFunctionConcurrencyOutput{}, nil
+}
+
+func (f *fakeLambda) GetFunctionConcurrencyWithContext(ctx aws.Context, in *lambda.GetFunctionConcurrencyInput, opts ...request.Option) (*lambda.GetFunctionConcurrencyOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[name]; !ok {
+		return nil, f.notFound(name)
+	}
+	var out lambda.GetFunctionConcurrencyOutput
+	if n, ok := f.reserved[name]; ok {
+		out.ReservedConcurrentExecutions = aws.Int64(n)
+	}
+	return &out, nil
+}
+
+func (f *fakeLambda) PutProvisionedConcurrencyConfigWithContext(ctx aws.Context, in *lambda.PutProvisionedConcurrencyConfigInput, opts ...request.Option) (*lambda.PutProvisionedConcurrencyConfigOutput, error) {
+	key := aws.StringValue(in.FunctionName) + ":" + aws.StringValue(in.Qualifier)
+	if _, ok := f.aliases[key]; !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "Alias not found: "+key, nil)
+	}
+	f.provisioned[key] = aws.Int64Value(in.ProvisionedConcurrentExecutions)
+	return &lambda.PutProvisionedConcurrencyConfigOutput{Status: aws.String(lambda.ProvisionedConcurrencyStatusEnumInProgress)}, nil
+}
+
+func (f *fakeLambda) GetProvisionedConcurrencyConfigWithContext(ctx aws.Context, in *lambda.GetProvisionedConcurrencyConfigInput, opts ...request.Option) (*lambda.GetProvisionedConcurrencyConfigOutput, error) {
+	key := aws.StringValue(in.FunctionName) + ":" + aws.StringValue(in.Qualifier)
+	n, ok := f.provisioned[key]
+	if !ok {
+		return nil, awserr.New(lambda.ErrCodeProvisionedConcurrencyConfigNotFoundException, "No Provisioned Concurrency Config found", nil)
+	}
+	return &lambda.GetProvisionedConcurrencyConfigOutput{
+		RequestedProvisionedConcurrentExecutions: aws.Int64(n),
+		AllocatedProvisionedConcurrentExecutions: aws.Int64(n),
+		Status:                                   aws.String(lambda.ProvisionedConcurrencyStatusEnumReady),
+	}, nil
+}
+
+func (f *fakeLambda) DeleteProvisionedConcurrencyConfigWithContext(ctx aws.Context, in *lambda.DeleteProvisionedConcurrencyConfigInput, opts ...request.Option) (*lambda.DeleteProvisionedConcurrencyConfigOutput, error) {
+	key := aws.StringValue(in.FunctionName) + ":" + aws.StringValue(in.Qualifier)
+	if _, ok := f.provisioned[key]; !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "No Provisioned Concurrency Config found", nil)
+	}
+	delete(f.provisioned, key)
+	return &lambda.DeleteProvisionedConcurrencyConfigOutput{}, nil
+}
+
+func (f *fakeLambda) ListProvisionedConcurrencyConfigsPagesWithContext(ctx aws.Context, in *lambda.ListProvisionedConcurrencyConfigsInput, fn func(*lambda.ListProvisionedConcurrencyConfigsOutput, bool) bool, opts ...request.Option) error {
+	var page lambda.ListProvisionedConcurrencyConfigsOutput
+	for key, n := range f.provisioned {
+		if baseName(key) == aws.StringValue(in.FunctionName) {
+			page.ProvisionedConcurrencyConfigs = append(page.ProvisionedConcurrencyConfigs, &lambda.ProvisionedConcurrencyConfigListItem{
+				FunctionArn:                              aws.String("arn:aws:lambda:eu-west-2:123456789012:function:" + key),
+				RequestedProvisionedConcurrentExecutions: aws.Int64(n),
+				Status:                                   aws.String(lambda.ProvisionedConcurrencyStatusEnumReady),
+			})
+		}
+	}
+	fn(&page, true)
+	return nil
+}
+
+func (f *fakeLambda) PutFunctionEventInvokeConfigWithContext(ctx aws.Context, in *lambda.PutFunctionEventInvokeConfigInput, opts ...request.Option) (*lambda.PutFunctionEventInvokeConfigOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[name]; !ok {
+		return nil, f.notFound(name)
+	}
+	f.invokeConfigs[name] = &lambda.GetFunctionEventInvokeConfigOutput{
+		MaximumRetryAttempts: in.MaximumRetryAttempts, MaximumEventAgeInSeconds: in.MaximumEventAgeInSeconds, DestinationConfig: in.DestinationConfig,
+	}
+	return &lambda.PutFunctionEventInvokeConfigOutput{}, nil
+}
+
+func (f *fakeLambda) UpdateFunctionEventInvokeConfigWithContext(ctx aws.Context, in *lambda.UpdateFunctionEventInvokeConfigInput, opts ...request.Option) (*lambda.UpdateFunctionEventInvokeConfigOutput, error) {
+	cfg, ok := f.invokeConfigs[aws.StringValue(in.FunctionName)]
+	if !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "The function has no event invoke config", nil)
+	}
+	if in.MaximumRetryAttempts != nil {
+		cfg.MaximumRetryAttempts = in.MaximumRetryAttempts
+	}
+	if in.MaximumEventAgeInSeconds != nil {
+		cfg.MaximumEventAgeInSeconds = in.MaximumEventAgeInSeconds
+	}
+	if in.DestinationConfig != nil {
+		cfg.DestinationConfig = in.DestinationConfig
+	}
+	return &lambda.UpdateFunctionEventInvokeConfigOutput{}, nil
+}
+
+func (f *fakeLambda) GetFunctionEventInvokeConfigWithContext(ctx aws.Context, in *lambda.GetFunctionEventInvokeConfigInput, opts ...request.Option) (*lambda.GetFunctionEventInvokeConfigOutput, error) {
+	cfg, ok := f.invokeConfigs[aws.StringValue(in.FunctionName)]
+	if !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "The function has no event invoke config", nil)
+	}
+	return cfg, nil
+}
+
+func (f *fakeLambda) PublishLayerVersionWithContext(ctx aws.Context, in *lambda.PublishLayerVersionInput, opts ...request.Option) (*lambda.PublishLayerVersionOutput, error) {
+	name := aws.StringValue(in.LayerName)
+	version := int64(1)
+	if n := len(f.layers[name]); n > 0 {
+		version = aws.Int64Value(f.layers[name][n-1].Version) + 1
+	}
+	arn := fmt.Sprintf("arn:aws:lambda:eu-west-2:123456789012:layer:%s:%d", name, version)
+	f.layers[name] = append(f.layers[name], &lambda.LayerVersionsListItem{
+		Version:            aws.Int64(version),
+		LayerVersionArn:    aws.String(arn),
+		Description:        in.Description,
+		CompatibleRuntimes: in.CompatibleRuntimes,
+	})
+	f.layerCode[arn] = codeOf(in.Content.ZipFile, in.Content.S3Bucket, in.Content.S3Key)
+	return &lambda.PublishLayerVersionOutput{
+		Version:            aws.Int64(version),
+		LayerVersionArn:    aws.String(arn),
+		Description:        in.Description,
+		CompatibleRuntimes: in.CompatibleRuntimes,
+	}, nil
+}
+
+// ListLayerVersionsPagesWithContext returns the versions oldest first, over a page each, to check they are sorted
+func (f *fakeLambda) ListLayerVersionsPagesWithContext(ctx aws.Context, in *lambda.ListLayerVersionsInput, fn func(*lambda.ListLayerVersionsOutput, bool) bool, opts ...request.Option) error {
+	versions := f.layers[aws.StringValue(in.LayerName)]
+	if len(versions) == 0 {
+		fn(&lambda.ListLayerVersionsOutput{}, true)
+		return nil
+	}
+	for i, v := range versions {
+		if !fn(&lambda.ListLayerVersionsOutput{LayerVersions: []*lambda.LayerVersionsListItem{v}}, i == len(versions)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeLambda) DeleteLayerVersionWithContext(ctx aws.Context, in *lambda.DeleteLayerVersionInput, opts ...request.Option) (*lambda.DeleteLayerVersionOutput, error) {
+	name := aws.StringValue(in.LayerName)
+	for i, v := range f.layers[name] {
+		if aws.Int64Value(v.Version) == aws.Int64Value(in.VersionNumber) {
+			f.layers[name] = append(f.layers[name][:i], f.layers[name][i+1:]...)
+			break
+		}
+	}
+	return &lambda.DeleteLayerVersionOutput{}, nil
+}
+
+func (f *fakeLambda) CreateEventSourceMappingWithContext(ctx aws.Context, in *lambda.CreateEventSourceMappingInput, opts ...request.Option) (*lambda.EventSourceMappingConfiguration, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[baseName(name)]; !ok {
+		return nil, f.notFound(name)
+	}
+	if f.unreadable > 0 {
+		f.unreadable--
+		return nil, awserr.New(lambda.ErrCodeInvalidParameterValueException,
+			"The provided execution role does not have permissions to call ReceiveMessage on SQS", nil)
+	}
+	for _, m := range f.mappings {
+		if aws.StringValue(m.EventSourceArn) == aws.StringValue(in.EventSourceArn) && strings.HasSuffix(aws.StringValue(m.FunctionArn), ":function:"+name) {
+			return nil, awserr.New(lambda.ErrCodeResourceConflictException, "An event source mapping with this function and source already exists", nil)
+		}
+	}
+	uuid := fmt.Sprintf("mapping-%d", len(f.mappings)+1)
+	f.mappings[uuid] = &lambda.EventSourceMappingConfiguration{
+		UUID:           aws.String(uuid),
+		FunctionArn:    aws.String("arn:aws:lambda:eu-west-2:123456789012:function:" + name),
+		EventSourceArn: in.EventSourceArn,
+		BatchSize:      in.BatchSize,
+		State:          aws.String("Creating"),
+	}
+	f.enabled[uuid] = aws.BoolValue(in.Enabled)
+	return f.mappings[uuid], nil
+}
+
+// GetEventSourceMappingWithContext moves a mapping on from Creating or Deleting on each call
+func (f *fakeLambda) GetEventSourceMappingWithContext(ctx aws.Context, in *lambda.GetEventSourceMappingInput, opts ...request.Option) (*lambda.EventSourceMappingConfiguration, error) {
+	uuid := aws.StringValue(in.UUID)
+	m, ok := f.mappings[uuid]
+	if !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "The resource you requested does not exist.", nil)
+	}
+	switch aws.StringValue(m.State) {
+	case "Creating":
+		m.State = aws.String("Disabled")
+		if f.enabled[uuid] {
+			m.State = aws.String("Enabled")
+		}
+	case "Deleting":
+		delete(f.mappings, uuid)
+	}
+	return m, nil
+}
+
+func (f *fakeLambda) ListEventSourceMappingsPagesWithContext(ctx aws.Context, in *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool, opts ...request.Option) error {
+	var page lambda.ListEventSourceMappingsOutput
+	for _, m := range f.mappings {
+		if strings.HasSuffix(aws.StringValue(m.FunctionArn), ":function:"+aws.StringValue(in.FunctionName)) {
+			page.EventSourceMappings = append(page.EventSourceMappings, m)
+		}
+	}
+	fn(&page, true)
+	return nil
+}
+
+func (f *fakeLambda) DeleteEventSourceMappingWithContext(ctx aws.Context, in *lambda.DeleteEventSourceMappingInput, opts ...request.Option) (*lambda.EventSourceMappingConfiguration, error) {
+	m, ok := f.mappings[aws.StringValue(in.UUID)]
+	if !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "The resource you requested does not exist.", nil)
+	}
+	m.State = aws.String("Deleting")
+	return m, nil
+}
+
+// InvokeWithContext echoes the payload back, the function fails when the payload is "fail"
+func (f *fakeLambda) InvokeWithContext(ctx aws.Context, in *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[baseName(name)]; !ok {
+		return nil, f.notFound(name)
+	}
+	f.invokeDeadline = 0
+	if deadline, ok := ctx.Deadline(); ok {
+		f.invokeDeadline = time.Until(deadline)
+	}
+	if aws.StringValue(in.InvocationType) == lambda.InvocationTypeEvent {
+		return &lambda.InvokeOutput{StatusCode: aws.Int64(202)}, nil
+	}
+	out := &lambda.InvokeOutput{StatusCode: aws.Int64(200), ExecutedVersion: aws.String("$LATEST"), Payload: in.Payload}
+	if string(in.Payload) == "fail" {
+		out.FunctionError = aws.String("Unhandled")
+	}
+	if aws.StringValue(in.LogType) == lambda.LogTypeTail {
+		out.LogResult = aws.String(base64.StdEncoding.EncodeToString([]byte("START RequestId: 1\nEND RequestId: 1\n")))
+	}
+	return out, nil
+}
+
+func (f *fakeLambda) GetAliasWithContext(ctx aws.Context, in *lambda.GetAliasInput, opts ...request.Option) (*lambda.AliasConfiguration, error) {
+	key := aws.StringValue(in.FunctionName) + ":" + aws.StringValue(in.Name)
+	version, ok := f.aliases[key]
+	if !ok {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "Alias not found: "+key, nil)
+	}
+	return &lambda.AliasConfiguration{Name: in.Name, FunctionVersion: aws.String(version)}, nil
+}
+
+func (f *fakeLambda) CreateAliasWithContext(ctx aws.Context, in *lambda.CreateAliasInput, opts ...request.Option) (*lambda.AliasConfiguration, error) {
+	f.aliases[aws.StringValue(in.FunctionName)+":"+aws.StringValue(in.Name)] = aws.StringValue(in.FunctionVersion)
+	return &lambda.AliasConfiguration{Name: in.Name, FunctionVersion: in.FunctionVersion}, nil
+}
+
+func (f *fakeLambda) GetFunctionConfigurationWithContext(ctx aws.Context, in *lambda.GetFunctionConfigurationInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	fn, ok := f.functions[baseName(aws.StringValue(in.FunctionName))]
+	if !ok {
+		return nil, f.notFound(aws.StringValue(in.FunctionName))
+	}
+	return fn, nil
+}
+
+func (f *fakeLambda) GetFunctionWithContext(ctx aws.Context, in *lambda.GetFunctionInput, opts ...request.Option) (*lambda.GetFunctionOutput, error) {
+	fn, ok := f.functions[aws.StringValue(in.FunctionName)]
+	if !ok {
+		return nil, f.notFound(aws.StringValue(in.FunctionName))
+	}
+	return &lambda.GetFunctionOutput{Configuration: fn}, nil
+}
+
+func (f *fakeLambda) DeleteFunctionWithContext(ctx aws.Context, in *lambda.DeleteFunctionInput, opts ...request.Option) (*lambda.DeleteFunctionOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[name]; !ok {
+		return nil, f.notFound(name)
+	}
+	delete(f.functions, name)
+	delete(f.permissions, name)
+	return &lambda.DeleteFunctionOutput{}, nil
+}
+
+func (f *fakeLambda) AddPermissionWithContext(ctx aws.Context, in *lambda.AddPermissionInput, opts ...request.Option) (*lambda.AddPermissionOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if _, ok := f.functions[baseName(name)]; !ok {
+		return nil, f.notFound(name)
+	}
+	if f.failPermission != nil && len(f.permissions[name]) >= f.failAfter {
+		return nil, f.failPermission
+	}
+	if contains(f.permissions[name], aws.StringValue(in.StatementId)) {
+		return nil, awserr.New(lambda.ErrCodeResourceConflictException, "The statement id provided already exists.", nil)
+	}
+	f.permissions[name] = append(f.permissions[name], aws.StringValue(in.StatementId))
+	f.sources[name+"/"+aws.StringValue(in.StatementId)] = aws.StringValue(in.SourceArn)
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+// GetPolicyWithContext returns the function's permissions as the policy document Lambda keeps them in
+func (f *fakeLambda) GetPolicyWithContext(ctx aws.Context, in *lambda.GetPolicyInput, opts ...request.Option) (*lambda.GetPolicyOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if len(f.permissions[name]) == 0 {
+		return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "The resource you requested does not exist.", nil)
+	}
+	var statements []string
+	for _, id := range f.permissions[name] {
+		statements = append(statements, fmt.Sprintf(`{"Sid":%q,"Effect":"Allow","Action":"lambda:InvokeFunction","Condition":{"ArnLike":{"AWS:SourceArn":%q}}}`,
+			id, f.sources[name+"/"+id]))
+	}
+	return &lambda.GetPolicyOutput{Policy: aws.String(`{"Version":"2012-10-17","Statement":[` + strings.Join(statements, ",") + `]}`)}, nil
+}
+
+func (f *fakeLambda) RemovePermissionWithContext(ctx aws.Context, in *lambda.RemovePermissionInput, opts ...request.Option) (*lambda.RemovePermissionOutput, error) {
+	name, id := aws.StringValue(in.FunctionName), aws.StringValue(in.StatementId)
+	for i, sid := range f.permissions[name] {
+		if sid == id {
+			f.permissions[name] = append(f.permissions[name][:i], f.permissions[name][i+1:]...)
+			delete(f.sources, name+"/"+id)
+			return &lambda.RemovePermissionOutput{}, nil
+		}
+	}
+	return nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "The resource you requested does not exist.", nil)
+}
+
+// fakeEvents keeps the rules and their targets by rule name, listing targets a page each
+type fakeEvents struct {
+	cloudwatcheventsiface.CloudWatchEventsAPI
+	rules   map[string]*cloudwatchevents.PutRuleInput
+	targets map[string][]*cloudwatchevents.Target
+}
+
+func newFakeEvents() *fakeEvents {
+	return &fakeEvents{rules: map[string]*cloudwatchevents.PutRuleInput{}, targets: map[string][]*cloudwatchevents.Target{}}
+}
+
+func (f *fakeEvents) notFound(rule string) error {
+	return awserr.New(cloudwatchevents.ErrCodeResourceNotFoundException, "Rule "+rule+" does not exist.", nil)
+}
+
+func (f *fakeEvents) PutRuleWithContext(ctx aws.Context, in *cloudwatchevents.PutRuleInput, opts ...request.Option) (*cloudwatchevents.PutRuleOutput, error) {
+	f.rules[aws.StringValue(in.Name)] = in
+	return &cloudwatchevents.PutRuleOutput{RuleArn: aws.String("arn:aws:events:eu-west-2:123456789012:rule/" + aws.StringValue(in.Name))}, nil
+}
+
+func (f *fakeEvents) PutTargetsWithContext(ctx aws.Context, in *cloudwatchevents.PutTargetsInput, opts ...request.Option) (*cloudwatchevents.PutTargetsOutput, error) {
+	rule := aws.StringValue(in.Rule)
+	if _, ok := f.rules[rule]; !ok {
+		return nil, f.notFound(rule)
+	}
+	for _, t := range in.Targets {
+		f.removeTarget(rule, aws.StringValue(t.Id))
+		f.targets[rule] = append(f.targets[rule], t)
+	}
+	return &cloudwatchevents.PutTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func (f *fakeEvents) removeTarget(rule, id string) {
+	for i, t := range f.targets[rule] {
+		if aws.StringValue(t.Id) == id {
+			f.targets[rule] = append(f.targets[rule][:i], f.targets[rule][i+1:]...)
+			return
+		}
+	}
+}
+
+func (f *fakeEvents) ListTargetsByRuleWithContext(ctx aws.Context, in *cloudwatchevents.ListTargetsByRuleInput, opts ...request.Option) (*cloudwatchevents.ListTargetsByRuleOutput, error) {
+	rule := aws.StringValue(in.Rule)
+	if _, ok := f.rules[rule]; !ok {
+		return nil, f.notFound(rule)
+	}
+	targets := f.targets[rule]
+	i := 0
+	if in.NextToken != nil {
+		fmt.Sscan(aws.StringValue(in.NextToken), &i)
+	}
+	if i >= len(targets) {
+		return &cloudwatchevents.ListTargetsByRuleOutput{}, nil
+	}
+	out := &cloudwatchevents.ListTargetsByRuleOutput{Targets: targets[i : i+1]}
+	if i+1 < len(targets) {
+		out.NextToken = aws.String(fmt.Sprint(i + 1))
+	}
+	return out, nil
+}
+
+func (f *fakeEvents) RemoveTargetsWithContext(ctx aws.Context, in *cloudwatchevents.RemoveTargetsInput, opts ...request.Option) (*cloudwatchevents.RemoveTargetsOutput, error) {
+	for _, id := range in.Ids {
+		f.removeTarget(aws.StringValue(in.Rule), aws.StringValue(id))
+	}
+	return &cloudwatchevents.RemoveTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+// DeleteRuleWithContext fails while the rule has targets, as Events does
+func (f *fakeEvents) DeleteRuleWithContext(ctx aws.Context, in *cloudwatchevents.DeleteRuleInput, opts ...request.Option) (*cloudwatchevents.DeleteRuleOutput, error) {
+	rule := aws.StringValue(in.Name)
+	if len(f.targets[rule]) > 0 {
+		return nil, awserr.New("ValidationException", "Rule can't be deleted since it has targets.", nil)
+	}
+	delete(f.rules, rule)
+	return &cloudwatchevents.DeleteRuleOutput{}, nil
+}
+
+// fakeAPIGateway tracks the pieces ConfigureAPIEndpoint wires up in live, keyed by kind and ID, and fails the
+// operation named by failOn
+type fakeAPIGateway struct {
+	apigatewayiface.APIGatewayAPI
+	apis   map[string]*apigateway.RestApi
+	live   map[string]bool
+	stages map[string]string
+	failOn string
+	nextID int
+	// resources holds the resources created on each API, invoked is what TestInvokeMethod returns for each
+	resources map[string][]*apigateway.Resource
+	invoked   map[string]*apigateway.TestInvokeMethodOutput
+}
+
+func newFakeAPIGateway() *fakeAPIGateway {
+	return &fakeAPIGateway{
+		apis: map[string]*apigateway.RestApi{}, live: map[string]bool{}, stages: map[string]string{},
+		resources: map[string][]*apigateway.Resource{}, invoked: map[string]*apigateway.TestInvokeMethodOutput{},
+	}
+}
+
+func (f *fakeAPIGateway) id(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s%d", prefix, f.nextID)
+}
+
+func (f *fakeAPIGateway) fail(op string) error {
+	if f.failOn == op {
+		return awserr.New(apigateway.ErrCodeBadRequestException, op+" failed", nil)
+	}
+	return nil
+}
+
+// put marks key live unless op is the one set to fail
+func (f *fakeAPIGateway) put(op, key string) error {
+	if err := f.fail(op); err != nil {
+		return err
+	}
+	f.live[key] = true
+	return nil
+}
+
+// del removes a live key, or returns a not found error
+func (f *fakeAPIGateway) del(key string) error {
+	if !f.live[key] {
+		return awserr.New(apigateway.ErrCodeNotFoundException, key+" not found", nil)
+	}
+	delete(f.live, key)
+	return nil
+}
+
+func (f *fakeAPIGateway) CreateRestApiWithContext(ctx aws.Context, in *apigateway.CreateRestApiInput, opts ...request.Option) (*apigateway.RestApi, error) {
+	api := &apigateway.RestApi{Id: aws.String(f.id("api")), Name: in.Name, Description: in.Description}
+	f.apis[aws.StringValue(api.Id)] = api
+	return api, nil
+}
+
+func (f *fakeAPIGateway) GetResourcesWithContext(ctx aws.Context, in *apigateway.GetResourcesInput, opts ...request.Option) (*apigateway.GetResourcesOutput, error) {
+	items := []*apigateway.Resource{{Id: aws.String("root-" + aws.StringValue(in.RestApiId)), Path: aws.String("/")}}
+	return &apigateway.GetResourcesOutput{Items: append(items, f.resources[aws.StringValue(in.RestApiId)]...)}, nil
+}
+
+func (f *fakeAPIGateway) GetResourcesPagesWithContext(ctx aws.Context, in *apigateway.GetResourcesInput, fn func(*apigateway.GetResourcesOutput, bool) bool, opts ...request.Option) error {
+	page, _ := f.GetResourcesWithContext(ctx, in)
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeAPIGateway) GetStagesWithContext(ctx aws.Context, in *apigateway.GetStagesInput, opts ...request.Option) (*apigateway.GetStagesOutput, error) {
+	var out apigateway.GetStagesOutput
+	for name, dep := range f.stages {
+		out.Item = append(out.Item, &apigateway.Stage{StageName: aws.String(name), DeploymentId: aws.String(dep)})
+	}
+	return &out, nil
+}
+
+func (f *fakeAPIGateway) TestInvokeMethodWithContext(ctx aws.Context, in *apigateway.TestInvokeMethodInput, opts ...request.Option) (*apigateway.TestInvokeMethodOutput, error) {
+	out, ok := f.invoked[aws.StringValue(in.ResourceId)]
+	if !ok {
+		return nil, awserr.New(apigateway.ErrCodeNotFoundException, "Invalid Resource identifier specified", nil)
+	}
+	return out, nil
+}
+
+func (f *fakeAPIGateway) CreateResourceWithContext(ctx aws.Context, in *apigateway.CreateResourceInput, opts ...request.Option) (*apigateway.Resource, error) {
+	id := f.id("res")
+	if err := f.put("CreateResource", "resource:"+id); err != nil {
+		return nil, err
+	}
+	res := &apigateway.Resource{Id: aws.String(id), PathPart: in.PathPart, ParentId: in.ParentId, Path: aws.String("/" + aws.StringValue(in.PathPart))}
+	f.resources[aws.StringValue(in.RestApiId)] = append(f.resources[aws.StringValue(in.RestApiId)], res)
+	return res, nil
+}
+
+func (f *fakeAPIGateway) DeleteResourceWithContext(ctx aws.Context, in *apigateway.DeleteResourceInput, opts ...request.Option) (*apigateway.DeleteResourceOutput, error) {
+	return &apigateway.DeleteResourceOutput{}, f.del("resource:" + aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) PutMethodWithContext(ctx aws.Context, in *apigateway.PutMethodInput, opts ...request.Option) (*apigateway.Method, error) {
+	return &apigateway.Method{}, f.put("PutMethod", "method:"+aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) DeleteMethodWithContext(ctx aws.Context, in *apigateway.DeleteMethodInput, opts ...request.Option) (*apigateway.DeleteMethodOutput, error) {
+	return &apigateway.DeleteMethodOutput{}, f.del("method:" + aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) PutIntegrationWithContext(ctx aws.Context, in *apigateway.PutIntegrationInput, opts ...request.Option) (*apigateway.Integration, error) {
+	return &apigateway.Integration{Uri: in.Uri}, f.put("PutIntegration", "integration:"+aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) DeleteIntegrationWithContext(ctx aws.Context, in *apigateway.DeleteIntegrationInput, opts ...request.Option) (*apigateway.DeleteIntegrationOutput, error) {
+	return &apigateway.DeleteIntegrationOutput{}, f.del("integration:" + aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) PutMethodResponseWithContext(ctx aws.Context, in *apigateway.PutMethodResponseInput, opts ...request.Option) (*apigateway.MethodResponse, error) {
+	return &apigateway.MethodResponse{}, f.put("PutMethodResponse", "methodResponse:"+aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) DeleteMethodResponseWithContext(ctx aws.Context, in *apigateway.DeleteMethodResponseInput, opts ...request.Option) (*apigateway.DeleteMethodResponseOutput, error) {
+	return &apigateway.DeleteMethodResponseOutput{}, f.del("methodResponse:" + aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) PutIntegrationResponseWithContext(ctx aws.Context, in *apigateway.PutIntegrationResponseInput, opts ...request.Option) (*apigateway.IntegrationResponse, error) {
+	return &apigateway.IntegrationResponse{}, f.put("PutIntegrationResponse", "integrationResponse:"+aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) DeleteIntegrationResponseWithContext(ctx aws.Context, in *apigateway.DeleteIntegrationResponseInput, opts ...request.Option) (*apigateway.DeleteIntegrationResponseOutput, error) {
+	return &apigateway.DeleteIntegrationResponseOutput{}, f.del("integrationResponse:" + aws.StringValue(in.ResourceId))
+}
+
+func (f *fakeAPIGateway) CreateDeploymentWithContext(ctx aws.Context, in *apigateway.CreateDeploymentInput, opts ...request.Option) (*apigateway.Deployment, error) {
+	id := f.id("dep")
+	if err := f.put("CreateDeployment", "deployment:"+id); err != nil {
+		return nil, err
+	}
+	f.live["stage:"+aws.StringValue(in.StageName)] = true
+	f.stages[aws.StringValue(in.StageName)] = id
+	return &apigateway.Deployment{Id: aws.String(id)}, nil
+}
+
+func (f *fakeAPIGateway) DeleteDeploymentWithContext(ctx aws.Context, in *apigateway.DeleteDeploymentInput, opts ...request.Option) (*apigateway.DeleteDeploymentOutput, error) {
+	return &apigateway.DeleteDeploymentOutput{}, f.del("deployment:" + aws.StringValue(in.DeploymentId))
+}
+
+func (f *fakeAPIGateway) GetStageWithContext(ctx aws.Context, in *apigateway.GetStageInput, opts ...request.Option) (*apigateway.Stage, error) {
+	dep, ok := f.stages[aws.StringValue(in.StageName)]
+	if !ok {
+		return nil, awserr.New(apigateway.ErrCodeNotFoundException, "Invalid stage identifier specified", nil)
+	}
+	return &apigateway.Stage{StageName: in.StageName, DeploymentId: aws.String(dep)}, nil
+}
+
+func (f *fakeAPIGateway) UpdateStageWithContext(ctx aws.Context, in *apigateway.UpdateStageInput, opts ...request.Option) (*apigateway.Stage, error) {
+	for _, op := range in.PatchOperations {
+		if aws.StringValue(op.Path) == "/deploymentId" {
+			f.stages[aws.StringValue(in.StageName)] = aws.StringValue(op.Value)
+		}
+	}
+	return &apigateway.Stage{StageName: in.StageName}, nil
+}
+
+func (f *fakeAPIGateway) DeleteStageWithContext(ctx aws.Context, in *apigateway.DeleteStageInput, opts ...request.Option) (*apigateway.DeleteStageOutput, error) {
+	delete(f.stages, aws.StringValue(in.StageName))
+	return &apigateway.DeleteStageOutput{}, f.del("stage:" + aws.StringValue(in.StageName))
+}
+
+// GetRestApisPagesWithContext returns the APIs sorted by ID two to a page, so lookups have to paginate
+func (f *fakeAPIGateway) GetRestApisPagesWithContext(ctx aws.Context, in *apigateway.GetRestApisInput, fn func(*apigateway.GetRestApisOutput, bool) bool, opts ...request.Option) error {
+	ids := make([]string, 0, len(f.apis))
+	for id := range f.apis {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var page apigateway.GetRestApisOutput
+	for i, id := range ids {
+		page.Items = append(page.Items, f.apis[id])
+		last := i == len(ids)-1
+		if len(page.Items) == 2 || last {
+			if !fn(&page, last) {
+				return nil
+			}
+			page = apigateway.GetRestApisOutput{}
+		}
+	}
+	if len(ids) == 0 {
+		fn(&page, true)
+	}
+	return nil
+}
+
+func (f *fakeAPIGateway) GetRestApiWithContext(ctx aws.Context, in *apigateway.GetRestApiInput, opts ...request.Option) (*apigateway.RestApi, error) {
+	api, ok := f.apis[aws.StringValue(in.RestApiId)]
+	if !ok {
+		return nil, awserr.New(apigateway.ErrCodeNotFoundException, "Invalid API identifier specified", nil)
+	}
+	return api, nil
+}
+
+func (f *fakeAPIGateway) DeleteRestApiWithContext(ctx aws.Context, in *apigateway.DeleteRestApiInput, opts ...request.Option) (*apigateway.DeleteRestApiOutput, error) {
+	id := aws.StringValue(in.RestApiId)
+	if _, ok := f.apis[id]; !ok {
+		return nil, awserr.New(apigateway.ErrCodeNotFoundException, "Invalid API identifier specified", nil)
+	}
+	delete(f.apis, id)
+	return &apigateway.DeleteRestApiOutput{}, nil
+}
+
+// fakeS3 keeps the objects of each bucket, with a version ID per upload when the bucket is versioned
+type fakeS3 struct {
+	s3iface.S3API
+	buckets   map[string]bool
+	versioned map[string]bool
+	objects   map[string][]byte
+	versions  map[string]string
+	puts      int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{buckets: map[string]bool{}, versioned: map[string]bool{}, objects: map[string][]byte{}, versions: map[string]string{}}
+}
+
+func (f *fakeS3) notFound(code string) error {
+	return awserr.New(code, "Not Found", nil)
+}
+
+func (f *fakeS3) HeadBucketWithContext(ctx aws.Context, in *s3.HeadBucketInput, opts ...request.Option) (*s3.HeadBucketOutput, error) {
+	if !f.buckets[aws.StringValue(in.Bucket)] {
+		return nil, f.notFound("NotFound")
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (f *fakeS3) CreateBucketWithContext(ctx aws.Context, in *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	if f.buckets[aws.StringValue(in.Bucket)] {
+		return nil, awserr.New(s3.ErrCodeBucketAlreadyOwnedByYou, "Your previous request to create the named bucket succeeded", nil)
+	}
+	f.buckets[aws.StringValue(in.Bucket)] = true
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeS3) PutBucketVersioningWithContext(ctx aws.Context, in *s3.PutBucketVersioningInput, opts ...request.Option) (*s3.PutBucketVersioningOutput, error) {
+	f.versioned[aws.StringValue(in.Bucket)] = aws.StringValue(in.VersioningConfiguration.Status) == s3.BucketVersioningStatusEnabled
+	return &s3.PutBucketVersioningOutput{}, nil
+}
+
+func (f *fakeS3) WaitUntilBucketExistsWithContext(ctx aws.Context, in *s3.HeadBucketInput, opts ...request.WaiterOption) error {
+	_, err := f.HeadBucketWithContext(ctx, in)
+	return err
+}
+
+func (f *fakeS3) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	key := aws.StringValue(in.Bucket) + "/" + aws.StringValue(in.Key)
+	if _, ok := f.objects[key]; !ok {
+		return nil, f.notFound("NotFound")
+	}
+	out := &s3.HeadObjectOutput{}
+	if v, ok := f.versions[key]; ok {
+		out.VersionId = aws.String(v)
+	}
+	return out, nil
+}
+
+func (f *fakeS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if !f.buckets[aws.StringValue(in.Bucket)] {
+		return nil, f.notFound(s3.ErrCodeNoSuchBucket)
+	}
+	body, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.puts++
+	key := aws.StringValue(in.Bucket) + "/" + aws.StringValue(in.Key)
+	f.objects[key] = body
+	out := &s3.PutObjectOutput{}
+	if f.versioned[aws.StringValue(in.Bucket)] {
+		f.versions[key] = fmt.Sprintf("v%d", f.puts)
+		out.VersionId = aws.String(f.versions[key])
+	}
+	return out, nil
+}
+
+// fakeEC2 reports the network interfaces of each function for a number of polls before releasing them
+type fakeEC2 struct {
+	ec2iface.EC2API
+	enis    map[string]int
+	deleted []string
+}
+
+func newFakeEC2() *fakeEC2 {
+	return &fakeEC2{enis: map[string]int{}}
+}
+
+func (f *fakeEC2) DescribeNetworkInterfacesWithContext(ctx aws.Context, in *ec2.DescribeNetworkInterfacesInput, opts ...request.Option) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	var out ec2.DescribeNetworkInterfacesOutput
+	for _, filter := range in.Filters {
+		if aws.StringValue(filter.Name) != "description" {
+			continue
+		}
+		// the filter's trailing wildcard matches any description starting with the rest of it
+		prefix := strings.TrimSuffix(aws.StringValue(filter.Values[0]), "*")
+		for fn := range f.enis {
+			description := eniDescriptionPrefix + fn + "-0b6cbc3e-7d0a-4f5e-9c61-2a4f3c1d8e9b"
+			if f.enis[fn] > 0 && strings.HasPrefix(description, prefix) {
+				f.enis[fn]--
+				out.NetworkInterfaces = append(out.NetworkInterfaces, &ec2.NetworkInterface{Description: aws.String(description)})
+			}
+		}
+	}
+	return &out, nil
+}
+
+func (f *fakeEC2) DeleteSecurityGroupWithContext(ctx aws.Context, in *ec2.DeleteSecurityGroupInput, opts ...request.Option) (*ec2.DeleteSecurityGroupOutput, error) {
+	f.deleted = append(f.deleted, aws.StringValue(in.GroupId))
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+// fakeCloudWatch reports the Errors metric of each function version from errors, counting the reads in checks
+type fakeCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	errors map[string]float64
+	checks int
+}
+
+func newFakeCloudWatch() *fakeCloudWatch {
+	return &fakeCloudWatch{errors: map[string]float64{}}
+}
+
+func (f *fakeCloudWatch) GetMetricStatisticsWithContext(ctx aws.Context, in *cloudwatch.GetMetricStatisticsInput, opts ...request.Option) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	f.checks++
+	var out cloudwatch.GetMetricStatisticsOutput
+	for _, d := range in.Dimensions {
+		if aws.StringValue(d.Name) == "ExecutedVersion" {
+			out.Datapoints = append(out.Datapoints, &cloudwatch.Datapoint{Sum: aws.Float64(f.errors[aws.StringValue(d.Value)])})
+		}
+	}
+	return &out, nil
+}
+
+// fakeLogs holds the events of each log group, returning them from FilterLogEvents a page per stream in the
+// order the streams were added. later is added to the events once they have been read once
+type fakeLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	groups map[string][]*cloudwatchlogs.FilteredLogEvent
+	later  []*cloudwatchlogs.FilteredLogEvent
+}
+
+func newFakeLogs() *fakeLogs {
+	return &fakeLogs{groups: map[string][]*cloudwatchlogs.FilteredLogEvent{}}
+}
+
+func (f *fakeLogs) FilterLogEventsPagesWithContext(ctx aws.Context, in *cloudwatchlogs.FilterLogEventsInput, fn func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool, opts ...request.Option) error {
+	group := aws.StringValue(in.LogGroupName)
+	events, ok := f.groups[group]
+	if !ok {
+		return awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.", nil)
+	}
+
+	pages := map[string]*cloudwatchlogs.FilterLogEventsOutput{}
+	var streams []string
+	for _, e := range events {
+		if aws.Int64Value(e.Timestamp) < aws.Int64Value(in.StartTime) || !strings.Contains(aws.StringValue(e.Message), aws.StringValue(in.FilterPattern)) {
+			continue
+		}
+		stream := aws.StringValue(e.LogStreamName)
+		if pages[stream] == nil {
+			pages[stream] = &cloudwatchlogs.FilterLogEventsOutput{}
+			streams = append(streams, stream)
+		}
+		pages[stream].Events = append(pages[stream].Events, e)
+	}
+	for i, stream := range streams {
+		if !fn(pages[stream], i == len(streams)-1) {
+			break
+		}
+	}
+
+	f.groups[group] = append(f.groups[group], f.later...)
+	f.later = nil
+	return nil
+}
+
+func newFakeProvisioner() (*Provisioner, *fakeIAM, *fakeLambda, *fakeAPIGateway) {
+	i, l, g := newFakeIAM(), newFakeLambda(), newFakeAPIGateway()
+	return &Provisioner{
+		IAMSvc:        i,
+		LambdaSvc:     l,
+		APIGatewaySvc: g,
+		S3Svc:         newFakeS3(),
+		EC2Svc:        newFakeEC2(),
+		CloudWatchSvc: newFakeCloudWatch(),
+		LogsSvc:       newFakeLogs(),
+		EventsSvc:     newFakeEvents(),
+		Region:        "eu-west-2",
+		Account:       "123456789012",
+	}, i, l, g
+}
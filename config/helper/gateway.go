@@ -0,0 +1,122 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// ErrRestAPINotFound is returned when no REST API has the requested name
+var ErrRestAPINotFound = errors.New("no REST API found")
+
+// ErrRestAPIAmbiguous is returned when more than one REST API has the requested name, REST API names are not
+// unique so the ID has to be given instead
+var ErrRestAPIAmbiguous = errors.New("more than one REST API found, supply the ID")
+
+// GatewayDescription is the REST API along with the resources and stages configured on it
+type GatewayDescription struct {
+	API       *apigateway.RestApi
+	Resources []*apigateway.Resource
+	Stages    []*apigateway.Stage
+}
+
+// SetupGateway creates the REST API for g and configures its endpoint to invoke g.FunctionName, or its alias
+// g.Alias which must already exist. If configuring the endpoint fails the REST API is deleted again, along with
+// the steps ConfigureAPIEndpoint rolls back itself
+func (p *Provisioner) SetupGateway(ctx context.Context, g Gateway) (*apigateway.RestApi, error) {
+	api, rootID, err := p.CreateGateway(ctx, g)
+	if err != nil {
+		return nil, fmt.Errorf("creating gateway %s: %w", g.Name, err)
+	}
+	fmt.Println("API Gateway created: ", api)
+
+	err = p.ConfigureAPIEndpoint(ctx, rootID, api.Id, api.Name, qualified(g.FunctionName, g.Alias))
+	if err != nil {
+		if _, derr := p.DeleteRestAPI(ctx, aws.StringValue(api.Id)); derr != nil {
+			return nil, fmt.Errorf("configuring gateway %s: %w (deleting REST API %s: %v)", g.Name, err, aws.StringValue(api.Id), derr)
+		}
+		return nil, fmt.Errorf("configuring gateway %s: %w", g.Name, err)
+	}
+	return api, nil
+}
+
+// RestAPIID returns the ID of the only REST API with the given name, it searches every page of GetRestApis
+func (p *Provisioner) RestAPIID(ctx context.Context, name string) (string, error) {
+	var ids []string
+	err := p.APIGatewaySvc.GetRestApisPagesWithContext(ctx, &apigateway.GetRestApisInput{},
+		func(page *apigateway.GetRestApisOutput, lastPage bool) bool {
+			for _, api := range page.Items {
+				if aws.StringValue(api.Name) == name {
+					ids = append(ids, aws.StringValue(api.Id))
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("%w with name %s", ErrRestAPINotFound, name)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("%w, %s matches %s", ErrRestAPIAmbiguous, name, strings.Join(ids, ", "))
+}
+
+// ResolveRestAPI returns the REST API ID of a gateway. An ID given explicitly is used as is, otherwise the ID
+// recorded in the state for the name, otherwise the name is looked up with RestAPIID
+func (p *Provisioner) ResolveRestAPI(ctx context.Context, name, id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	if p.State != nil && p.State.Gateways[name] != nil && p.State.Gateways[name].RestAPIID != "" {
+		return p.State.Gateways[name].RestAPIID, nil
+	}
+	return p.RestAPIID(ctx, name)
+}
+
+// DescribeGateway returns the REST API with the given ID and the resources and stages configured on it
+func (p *Provisioner) DescribeGateway(ctx context.Context, id string) (*GatewayDescription, error) {
+	api, err := p.APIGatewaySvc.GetRestApiWithContext(ctx, &apigateway.GetRestApiInput{RestApiId: aws.String(id)})
+	if err != nil {
+		return nil, err
+	}
+	d := &GatewayDescription{API: api}
+
+	err = p.APIGatewaySvc.GetResourcesPagesWithContext(ctx, &apigateway.GetResourcesInput{RestApiId: aws.String(id)},
+		func(page *apigateway.GetResourcesOutput, lastPage bool) bool {
+			d.Resources = append(d.Resources, page.Items...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err := p.APIGatewaySvc.GetStagesWithContext(ctx, &apigateway.GetStagesInput{RestApiId: aws.String(id)})
+	if err != nil {
+		return nil, err
+	}
+	d.Stages = stages.Item
+	return d, nil
+}
+
+// UpdateGateway replaces the description of the REST API with the given ID
+func (p *Provisioner) UpdateGateway(ctx context.Context, id string, g Gateway) (*apigateway.RestApi, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.APIGatewaySvc.UpdateRestApiWithContext(ctx, &apigateway.UpdateRestApiInput{
+		RestApiId: aws.String(id),
+		PatchOperations: []*apigateway.PatchOperation{{
+			Op:    aws.String(apigateway.OpReplace),
+			Path:  aws.String("/description"),
+			Value: aws.String(g.Description),
+		}},
+	})
+}
@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+func TestRestAPIID(t *testing.T) {
+	p, _, _, g := newFakeProvisioner()
+	ctx := context.Background()
+	for id, name := range map[string]string{"a1": "other", "b2": "other", "c3": "stack-api", "d4": "dup", "e5": "dup"} {
+		g.apis[id] = &apigateway.RestApi{Id: aws.String(id), Name: aws.String(name)}
+	}
+
+	id, err := p.RestAPIID(ctx, "stack-api")
+	if err != nil || id != "c3" {
+		t.Errorf("RestAPIID(%q) failed, expected c3 from the second page, got %v %v", "stack-api", id, err)
+	}
+	if _, err := p.RestAPIID(ctx, "missing"); !errors.Is(err, ErrRestAPINotFound) {
+		t.Errorf("RestAPIID(%q) failed, expected %v, got %v", "missing", ErrRestAPINotFound, err)
+	}
+	if _, err := p.RestAPIID(ctx, "dup"); !errors.Is(err, ErrRestAPIAmbiguous) {
+		t.Errorf("RestAPIID(%q) failed, expected %v, got %v", "dup", ErrRestAPIAmbiguous, err)
+	}
+}
+
+func TestResolveRestAPI(t *testing.T) {
+	p, _, _, g := newFakeProvisioner()
+	ctx := context.Background()
+	g.apis["c3"] = &apigateway.RestApi{Id: aws.String("c3"), Name: aws.String("stack-api")}
+
+	if id, _ := p.ResolveRestAPI(ctx, "stack-api", "x9"); id != "x9" {
+		t.Errorf("ResolveRestAPI failed, expected the explicit ID x9, got %v", id)
+	}
+	if id, _ := p.ResolveRestAPI(ctx, "stack-api", ""); id != "c3" {
+		t.Errorf("ResolveRestAPI failed, expected the looked up ID c3, got %v", id)
+	}
+
+	p.State = &state.State{Gateways: map[string]*state.Gateway{"stack-api": {RestAPIID: "r7"}}}
+	if id, _ := p.ResolveRestAPI(ctx, "stack-api", ""); id != "r7" {
+		t.Errorf("ResolveRestAPI failed, expected the recorded ID r7, got %v", id)
+	}
+}
@@ -1,18 +1,38 @@
 package helper
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"reflect"
 	"strings"
 	"time"
 
+	"github.com/VariableExp0rt/lambda-and-fun/config/deploy"
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/apigateway/apigatewayiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
 
 const (
@@ -20,14 +40,65 @@ const (
 	policyArnPrefixServiceRole = "arn:aws:iam::aws:policy/service-role/"
 )
 
-// TODO: Add WaitUntil functions to each of those below so we know when the service is actually up, or timeout
+// gatewayStatementIDs are the statement IDs of the permissions allowing API Gateway to invoke a function
+var gatewayStatementIDs = []string{"apigateway-test-2", "apigateway-prod-2"}
+
+// Provisioner holds the AWS service clients used to create and delete resources. The clients are interfaces
+// so that fakes can be swapped in for testing, or the package embedded as a library with pre-configured clients
+type Provisioner struct {
+	IAMSvc        iamiface.IAMAPI
+	LambdaSvc     lambdaiface.LambdaAPI
+	APIGatewaySvc apigatewayiface.APIGatewayAPI
+	S3Svc         s3iface.S3API
+	EC2Svc        ec2iface.EC2API
+	CloudWatchSvc cloudwatchiface.CloudWatchAPI
+	LogsSvc       cloudwatchlogsiface.CloudWatchLogsAPI
+	EventsSvc     cloudwatcheventsiface.CloudWatchEventsAPI
+	Region        string
+	Account       string
+	// Timeout bounds how long each operation waits for its resource to reach the end state
+	Timeout time.Duration
+	// ENITimeout bounds how long DeleteLambda waits for Lambda to release the network interfaces of a VPC function
+	ENITimeout time.Duration
+	// State, when set, records the identifiers of everything created and forgets everything deleted
+	State *state.State
+	// OnConflict decides whether creating a role or function which already exists fails, adopts it or
+	// updates it
+	OnConflict ConflictMode
+	// Bucket is the S3 bucket deployment packages are staged in, when empty only packages too large to send
+	// inline are staged, in a bucket named after the account and region
+	Bucket string
+	// DryRun skips waiting and recording state, as set by NewDryRunProvisioner whose clients only record
+	// the calls which would change something
+	DryRun bool
+}
+
+// NewProvisioner creates a Provisioner with a client for each service built from the given session, the
+// account ID defaults to the 'account' environment variable
+func NewProvisioner(sess *session.Session) *Provisioner {
+	return &Provisioner{
+		IAMSvc:        iam.New(sess),
+		LambdaSvc:     lambda.New(sess),
+		APIGatewaySvc: apigateway.New(sess),
+		S3Svc:         s3.New(sess),
+		EC2Svc:        ec2.New(sess),
+		CloudWatchSvc: cloudwatch.New(sess),
+		LogsSvc:       cloudwatchlogs.New(sess),
+		EventsSvc:     cloudwatchevents.New(sess),
+		Region:        aws.StringValue(sess.Config.Region),
+		Account:       os.Getenv("account"),
+		Timeout:       DefaultTimeout,
+		ENITimeout:    DefaultENITimeout,
+		OnConflict:    ConflictFail,
+	}
+}
 
 // Role is a data structure to hold the number of roles the function should create, the names it should use
 // to do so, and a
 type Role struct {
-	RoleName    string
-	Description string
-	Service     string
+	RoleName    string `yaml:"name"`
+	Description string `yaml:"description"`
+	Service     string `yaml:"service"`
 }
 
 // AttachPolicyInput holds the data for the AttachPolicy function
@@ -39,24 +110,124 @@ type AttachPolicyInput struct {
 
 // Lambda provides the necessary required fields to create a minimal Lambda function for our purposes, creates a
 // service instance using the session created in session.go and then our input as the CreateFunctionInput arguments
-// , it returns a *lambda.Function
+// , it returns a *lambda.Function. The runtime settings after Role are optional, zero values leave Lambda's
+// defaults in place on create and the current setting in place on update
 type Lambda struct {
-	Code         string
-	Description  string
-	FunctionName string
-	Handler      string
-	Runtime      string
-	Role         string
+	Code string `yaml:"codePath"`
+	// Source is a directory of Go source to build the deployment package from, instead of using Code
+	Source       string `yaml:"source"`
+	Description  string `yaml:"description"`
+	FunctionName string `yaml:"name"`
+	Handler      string `yaml:"handler"`
+	Runtime      string `yaml:"runtime"`
+	Role         string `yaml:"role"`
+
+	// MemorySize is in MB and Timeout in seconds
+	MemorySize  int64             `yaml:"memorySize"`
+	Timeout     int64             `yaml:"timeout"`
+	Environment map[string]string `yaml:"environment"`
+	Tags        map[string]string `yaml:"tags"`
+	// Layers are layer version ARNs, layer names for their latest version or names and versions as "name:3"
+	Layers []string `yaml:"layers"`
+	// TracingMode is Active or PassThrough
+	TracingMode string `yaml:"tracingMode"`
+	// DeadLetterTarget is the ARN of the SQS queue or SNS topic failed asynchronous invocations are sent to
+	DeadLetterTarget string `yaml:"deadLetterTarget"`
+	// KMSKeyArn is the key the environment variables are encrypted with instead of the Lambda managed key
+	KMSKeyArn string `yaml:"kmsKeyArn"`
+	// SubnetIDs and SecurityGroupIDs attach the function to a VPC, the VPC access execution policy is attached
+	// to its role automatically
+	SubnetIDs        []string `yaml:"subnetIds"`
+	SecurityGroupIDs []string `yaml:"securityGroupIds"`
+	// ReservedConcurrency reserves concurrent executions for the function and caps it at them, nil leaves it
+	// on the account's unreserved pool. ProvisionedConcurrency keeps that many execution environments of the
+	// alias the function is published to initialised, so it needs an alias
+	ReservedConcurrency    *int64 `yaml:"reservedConcurrency"`
+	ProvisionedConcurrency int64  `yaml:"provisionedConcurrency"`
+	// MaximumRetryAttempts, 0 to 2 and nil for Lambda's default, and MaximumEventAge, in seconds, bound how
+	// asynchronous invocations are retried. OnSuccess and OnFailure are the ARNs of the SQS queue, SNS topic,
+	// Lambda function or EventBridge bus the record of each asynchronous invocation is sent to
+	MaximumRetryAttempts *int64 `yaml:"maximumRetryAttempts"`
+	MaximumEventAge      int64  `yaml:"maximumEventAge"`
+	OnSuccess            string `yaml:"onSuccess"`
+	OnFailure            string `yaml:"onFailure"`
+}
+
+// configuration returns the update of the configuration of an existing function to match l, or nil when l
+// sets nothing to change
+func (l Lambda) configuration() *lambda.UpdateFunctionConfigurationInput {
+	in := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(l.FunctionName),
+		Description:  optional(l.Description),
+		Handler:      optional(l.Handler),
+		Role:         optional(l.Role),
+		Runtime:      optional(l.Runtime),
+		KMSKeyArn:    optional(l.KMSKeyArn),
+		Environment:  l.environment(),
+	}
+	if len(l.Layers) > 0 {
+		in.Layers = aws.StringSlice(l.Layers)
+	}
+	if l.MemorySize > 0 {
+		in.MemorySize = aws.Int64(l.MemorySize)
+	}
+	if l.Timeout > 0 {
+		in.Timeout = aws.Int64(l.Timeout)
+	}
+	if l.TracingMode != "" {
+		in.TracingConfig = &lambda.TracingConfig{Mode: aws.String(l.TracingMode)}
+	}
+	if l.DeadLetterTarget != "" {
+		in.DeadLetterConfig = &lambda.DeadLetterConfig{TargetArn: aws.String(l.DeadLetterTarget)}
+	}
+	in.VpcConfig = l.vpcConfig()
+
+	if reflect.DeepEqual(*in, lambda.UpdateFunctionConfigurationInput{FunctionName: in.FunctionName}) {
+		return nil
+	}
+	return in
+}
+
+// createInput returns the request creating the function l describes with the given code
+func (l Lambda) createInput(code *lambda.FunctionCode) *lambda.CreateFunctionInput {
+	in := &lambda.CreateFunctionInput{
+		Code:         code,
+		Description:  aws.String(l.Description),
+		FunctionName: aws.String(l.FunctionName),
+		Handler:      aws.String(l.Handler),
+		Role:         aws.String(l.Role),
+		Runtime:      aws.String(l.Runtime),
+	}
+	if cfg := l.configuration(); cfg != nil {
+		in.MemorySize, in.Timeout, in.Environment, in.Layers = cfg.MemorySize, cfg.Timeout, cfg.Environment, cfg.Layers
+		in.TracingConfig, in.DeadLetterConfig, in.KMSKeyArn = cfg.TracingConfig, cfg.DeadLetterConfig, cfg.KMSKeyArn
+		in.VpcConfig = cfg.VpcConfig
+	}
+	if len(l.Tags) > 0 {
+		in.Tags = aws.StringMap(l.Tags)
+	}
+	return in
+}
+
+func (l Lambda) environment() *lambda.Environment {
+	if len(l.Environment) == 0 {
+		return nil
+	}
+	return &lambda.Environment{Variables: aws.StringMap(l.Environment)}
 }
 
 // Gateway provides the configuration data for creating a REST API, HTTP API, or another kind of gateway
 // it uses the session to create a service and then invoke the creation with the parameters supplied by this
 // data structure
 type Gateway struct {
-	Name         string
-	Type         string
-	Description  string
-	FunctionName string
+	ID           string `yaml:"id"`
+	Name         string `yaml:"name"`
+	Type         string `yaml:"type"`
+	Description  string `yaml:"description"`
+	FunctionName string `yaml:"functionName"`
+	// Alias is the alias of the function the integration invokes, so traffic follows the alias as it is moved
+	// between versions. When empty the unqualified function is invoked
+	Alias string `yaml:"alias"`
 }
 
 var seededRand *rand.Rand = rand.New(
@@ -73,225 +244,635 @@ var R = func(length int, chars string) string {
 
 // CreateRole creates a given number of IAM roles with the required parameters only as input to the function
 // Singular would be easy to express, multiple roles can be created by running this function multiple times
-func CreateRole(args Role, sess *session.Session) (*iam.Role, error) {
-
-	svc := iam.New(sess)
+// It waits until the role is visible to IAM before returning, an existing role of the same name is handled
+// according to OnConflict
+func (p *Provisioner) CreateRole(ctx context.Context, args Role) (*iam.Role, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	role, err := svc.CreateRole(&iam.CreateRoleInput{
+	var role *iam.Role
+	res, err := p.IAMSvc.CreateRoleWithContext(ctx, &iam.CreateRoleInput{
 		RoleName:                 aws.String(args.RoleName),
-		AssumeRolePolicyDocument: aws.String("{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Principal\": {\"Service\": \"" + args.Service + "\"},\"Action\": \"sts:AssumeRole\"}]}"),
+		AssumeRolePolicyDocument: aws.String(assumeRolePolicy(args.Service)),
 		Description:              aws.String(args.Description),
 	})
-	time.Sleep(6 * time.Second)
-	return role.Role, err
+	if isAlreadyExists(err) {
+		role, err = p.existingRole(ctx, args, err)
+	} else if err == nil {
+		role = res.Role
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = p.wait(func() error {
+		return p.IAMSvc.WaitUntilRoleExistsWithContext(ctx, &iam.GetRoleInput{RoleName: aws.String(args.RoleName)}, waiterOptions()...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("waiting for role %s: %w", args.RoleName, err)
+	}
+	p.record(func(s *state.State) { recordedRole(s, args.RoleName).Arn = aws.StringValue(role.Arn) })
+	return role, nil
+}
+
+// assumeRolePolicy returns the trust policy allowing the given service to assume a role
+func assumeRolePolicy(service string) string {
+	return "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Principal\": {\"Service\": \"" + service + "\"},\"Action\": \"sts:AssumeRole\"}]}"
 }
 
 // AttachPolicy is used to attach policies to roles that have previously been created
 // supply managed ARN of the policy e.g. AWSLambdaBasicExecutionRole or AWSEKSClusterPolicy
 // This function needs restructing, cannot pass r *Role as argument
-func AttachPolicy(ap AttachPolicyInput, sess *session.Session) (*iam.AttachRolePolicyOutput, error) {
-	svc := iam.New(sess)
-
-	var err error
-	var res *iam.AttachRolePolicyOutput
-
-	// If is managed policy specifically linked to a role which is linked to a specific service, this applies
-	// else use the normal policy prefix instead of a service-role prefix
-	var s = func(AttachPolicyInput) string { newStr := strings.Split(ap.Service, "."); return newStr[0] }
-	if strings.Contains(ap.Policy, s(ap)) {
-		res, err = svc.AttachRolePolicy(&iam.AttachRolePolicyInput{
-			PolicyArn: aws.String(policyArnPrefixServiceRole + ap.Policy),
-			RoleName:  aws.String(ap.RoleName),
-		})
-		if err != nil {
-			fmt.Println(err.Error())
-		}
-	} else {
-		res, err = svc.AttachRolePolicy(&iam.AttachRolePolicyInput{
-			PolicyArn: aws.String(policyArnPrefix + ap.Policy),
-			RoleName:  aws.String(ap.RoleName),
+func (p *Provisioner) AttachPolicy(ctx context.Context, ap AttachPolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	arn := policyArn(ap)
+	res, err := p.IAMSvc.AttachRolePolicyWithContext(ctx, &iam.AttachRolePolicyInput{
+		PolicyArn: aws.String(arn),
+		RoleName:  aws.String(ap.RoleName),
+	})
+	if err != nil {
+		return res, err
+	}
+	p.record(func(s *state.State) {
+		r := recordedRole(s, ap.RoleName)
+		r.Policies = append(remove(r.Policies, arn), arn)
+	})
+	err = p.wait(func() error {
+		return poll(ctx, func() (bool, error) { return p.policyAttached(ctx, ap.RoleName, arn) })
+	})
+	if err != nil {
+		return res, fmt.Errorf("waiting for policy %s to attach to role %s: %w", arn, ap.RoleName, err)
+	}
+	return res, nil
+}
+
+// functionRoleName returns the name of the role given by ARN, or of the role of the function when role is empty
+func (p *Provisioner) functionRoleName(ctx context.Context, funcName, role string) (string, error) {
+	if role == "" {
+		fn, err := p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(funcName),
 		})
 		if err != nil {
-			fmt.Println(err.Error())
+			return "", fmt.Errorf("looking up the role of function %s: %w", funcName, err)
 		}
+		role = aws.StringValue(fn.Role)
 	}
-	time.Sleep(6 * time.Second)
-	return res, err
+	return role[strings.LastIndex(role, "/")+1:], nil
+}
+
+// policyArn returns the ARN of a managed policy. If is managed policy specifically linked to a role which is
+// linked to a specific service, the service-role prefix applies, else use the normal policy prefix
+func policyArn(ap AttachPolicyInput) string {
+	if strings.HasPrefix(ap.Policy, "arn:") {
+		return ap.Policy
+	}
+	if strings.Contains(ap.Policy, strings.Split(ap.Service, ".")[0]) {
+		return policyArnPrefixServiceRole + ap.Policy
+	}
+	return policyArnPrefix + ap.Policy
 }
 
 // CreateLambda creates a new Lambda function where Lambda is the input - only the required fields have
-// been included for ease
-func CreateLambda(l Lambda, sess *session.Session) (*lambda.FunctionConfiguration, error) {
-	svc := lambda.New(sess)
+// been included for ease. A newly created role can take a few seconds before Lambda is able to assume it, so
+// creation is retried until then, and it waits until the function is Active before returning. An existing
+// function of the same name is handled according to OnConflict. Without a handler the function gets the
+// runtime's default, see deploy.Handler. Layers given by name are resolved to their version ARNs. An adopted
+// function is used as it is, so its reserved concurrency and asynchronous invocation config are left alone
+func (p *Provisioner) CreateLambda(ctx context.Context, l Lambda) (*lambda.FunctionConfiguration, error) {
+	res, _, err := p.createLambda(ctx, l)
+	return res, err
+}
 
-	pkg, err := ioutil.ReadFile(l.Code)
+// createLambda is CreateLambda, also reporting whether an existing function was adopted rather than created
+// or updated
+func (p *Provisioner) createLambda(ctx context.Context, l Lambda) (*lambda.FunctionConfiguration, bool, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if l.Handler = deploy.Handler(l.Handler, l.Runtime); l.Handler == "" {
+		return nil, false, fmt.Errorf("function %s needs a handler for the %s runtime", l.FunctionName, l.Runtime)
+	}
+	if err := l.checkDestinations(); err != nil {
+		return nil, false, err
+	}
+	layers, err := p.resolveLayers(ctx, l.Layers)
 	if err != nil {
-		fmt.Println(err.Error(), l.Code)
+		return nil, false, err
 	}
+	l.Layers = layers
 
-	res, err := svc.CreateFunction(&lambda.CreateFunctionInput{
-		Code:         &lambda.FunctionCode{ZipFile: pkg},
-		Description:  aws.String(l.Description),
-		FunctionName: aws.String(l.FunctionName),
-		Handler:      aws.String(l.Handler),
-		Role:         aws.String(l.Role),
-		Runtime:      aws.String(l.Runtime),
+	if l.inVPC() {
+		if err := p.attachVPCAccess(ctx, l); err != nil {
+			return nil, false, err
+		}
+	}
+
+	pkg, err := p.deploymentPackage(ctx, l)
+	if err != nil {
+		return nil, false, err
+	}
+	if pkg == nil {
+		return nil, false, fmt.Errorf("function %s needs a code path or source directory", l.FunctionName)
+	}
+	code, err := p.functionCode(ctx, l.FunctionName, pkg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var res *lambda.FunctionConfiguration
+	err = poll(ctx, func() (bool, error) {
+		res, err = p.LambdaSvc.CreateFunctionWithContext(ctx, l.createInput(code))
+		if isRoleNotAssumable(err) {
+			return false, nil
+		}
+		return true, err
 	})
+	adopted := false
+	if isAlreadyExists(err) {
+		adopted = p.OnConflict == ConflictAdopt
+		res, err = p.existingFunction(ctx, l, code, err)
+	}
 	if err != nil {
-		fmt.Printf(err.Error())
+		return res, false, err
 	}
-	time.Sleep(6 * time.Second)
-	return res, err
+	p.record(func(s *state.State) {
+		f := recordedFunction(s, l.FunctionName)
+		f.Arn, f.Role = aws.StringValue(res.FunctionArn), l.Role
+	})
+	err = p.wait(func() error {
+		return p.LambdaSvc.WaitUntilFunctionActiveWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(l.FunctionName),
+		}, waiterOptions()...)
+	})
+	if err != nil {
+		return res, adopted, fmt.Errorf("waiting for function %s to become active: %w", l.FunctionName, err)
+	}
+	if l.ReservedConcurrency != nil && !adopted {
+		if err := p.SetReservedConcurrency(ctx, l.FunctionName, l.ReservedConcurrency); err != nil {
+			return res, adopted, err
+		}
+	}
+	if !adopted {
+		if err := p.setInvokeConfig(ctx, l); err != nil {
+			return res, adopted, err
+		}
+	}
+	return res, adopted, nil
+}
+
+// isRoleNotAssumable reports whether err is Lambda rejecting a role which IAM has not finished propagating,
+// either the role itself or, for a function in a VPC, the access policy letting it create network interfaces
+func isRoleNotAssumable(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == lambda.ErrCodeInvalidParameterValueException &&
+		(strings.Contains(aerr.Message(), "cannot be assumed by Lambda") ||
+			strings.Contains(aerr.Message(), "does not have permissions to call CreateNetworkInterface"))
+}
+
+// UpdateLambda changes the existing function l.FunctionName in place, which keeps the permissions added to it.
+// Only the fields of l which are set are changed and the code is only replaced when l.Code is given. With
+// publish a version is published once the update is done, and a non-empty alias is created or moved to point
+// at it, so giving an alias always publishes a version. Layers given by name are resolved to the version ARNs,
+// and replace those the function has. Moving to a provided runtime without a handler sets the handler to
+// bootstrap. Provisioned concurrency is set on the alias once it points at the new version
+func (p *Provisioner) UpdateLambda(ctx context.Context, l Lambda, publish bool, alias string) (*lambda.FunctionConfiguration, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if deploy.IsProvided(l.Runtime) {
+		l.Handler = deploy.Handler(l.Handler, l.Runtime)
+	}
+	if l.ProvisionedConcurrency > 0 && alias == "" {
+		return nil, fmt.Errorf("function %s needs an alias to provision concurrency for", l.FunctionName)
+	}
+	if err := l.checkDestinations(); err != nil {
+		return nil, err
+	}
+	layers, err := p.resolveLayers(ctx, l.Layers)
+	if err != nil {
+		return nil, err
+	}
+	l.Layers = layers
+
+	if l.inVPC() {
+		if err := p.attachVPCAccess(ctx, l); err != nil {
+			return nil, err
+		}
+	}
+
+	pkg, err := p.deploymentPackage(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	var code *lambda.FunctionCode
+	if pkg != nil {
+		if code, err = p.functionCode(ctx, l.FunctionName, pkg); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := p.updateFunction(ctx, l, code, publish || alias != "")
+	if err != nil {
+		return nil, err
+	}
+	p.record(func(s *state.State) {
+		f := recordedFunction(s, l.FunctionName)
+		f.Arn = aws.StringValue(res.FunctionArn)
+		if l.Role != "" {
+			f.Role = l.Role
+		}
+	})
+	if l.ReservedConcurrency != nil {
+		if err := p.SetReservedConcurrency(ctx, l.FunctionName, l.ReservedConcurrency); err != nil {
+			return res, err
+		}
+	}
+	if err := p.setInvokeConfig(ctx, l); err != nil {
+		return res, err
+	}
+	if alias == "" {
+		return res, nil
+	}
+
+	if err := p.pointAlias(ctx, l.FunctionName, alias, aws.StringValue(res.Version)); err != nil {
+		return res, err
+	}
+	if l.ProvisionedConcurrency > 0 {
+		if err := p.SetProvisionedConcurrency(ctx, l.FunctionName, alias, l.ProvisionedConcurrency); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// deploymentPackage returns the zip to upload for l; built from l.Source, read from l.Code, or nil when neither
+// is given. The package is checked with deploy.Validate before it is returned, the handler and runtime are
+// looked up on the existing function when l does not give them
+func (p *Provisioner) deploymentPackage(ctx context.Context, l Lambda) ([]byte, error) {
+	if l.Source != "" && l.Code != "" {
+		return nil, fmt.Errorf("function %s has both a code path and a source directory, give one", l.FunctionName)
+	}
+	if l.Source == "" && l.Code == "" {
+		return nil, nil
+	}
+
+	handler, runtime := l.Handler, l.Runtime
+	if handler == "" || runtime == "" {
+		fn, err := p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(l.FunctionName),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("looking up the handler of function %s: %w", l.FunctionName, err)
+		}
+		if handler == "" {
+			handler = aws.StringValue(fn.Handler)
+		}
+		if runtime == "" {
+			runtime = aws.StringValue(fn.Runtime)
+		}
+	}
+
+	var (
+		pkg []byte
+		err error
+	)
+	if l.Source != "" {
+		if !deploy.Buildable(runtime) {
+			return nil, fmt.Errorf("function %s: only go1.x and provided runtime packages can be built from source, not %s", l.FunctionName, runtime)
+		}
+		pkg, err = deploy.Build(ctx, l.Source, handler, runtime)
+	} else {
+		pkg, err = ioutil.ReadFile(l.Code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := deploy.Validate(pkg, handler, runtime); err != nil {
+		return nil, fmt.Errorf("function %s: %w", l.FunctionName, err)
+	}
+	return pkg, nil
+}
+
+// pointAlias moves the alias of a function to the given version, creating the alias if it does not exist. Any
+// traffic the alias routes to another version is routed to the given version too
+func (p *Provisioner) pointAlias(ctx context.Context, funcName, alias, version string) error {
+	_, err := p.LambdaSvc.UpdateAliasWithContext(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(funcName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		RoutingConfig:   &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{}},
+	})
+	if isNotFound(err) {
+		_, err = p.LambdaSvc.CreateAliasWithContext(ctx, &lambda.CreateAliasInput{
+			FunctionName:    aws.String(funcName),
+			Name:            aws.String(alias),
+			FunctionVersion: aws.String(version),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("pointing alias %s of function %s at version %s: %w", alias, funcName, version, err)
+	}
+	p.record(func(s *state.State) {
+		f := recordedFunction(s, funcName)
+		if f.Aliases == nil {
+			f.Aliases = map[string]string{}
+		}
+		f.Aliases[alias] = version
+	})
+	return nil
 }
 
 // CreateGateway creates a new API Gateway (REST of HTTP) with Gateway as the required input
-func CreateGateway(g Gateway, sess *session.Session) (*apigateway.RestApi, *string, error) {
-	svc := apigateway.New(sess)
+func (p *Provisioner) CreateGateway(ctx context.Context, g Gateway) (*apigateway.RestApi, *string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	res, err := svc.CreateRestApi(&apigateway.CreateRestApiInput{
+	res, err := p.APIGatewaySvc.CreateRestApiWithContext(ctx, &apigateway.CreateRestApiInput{
 		Description: aws.String(g.Description),
 		Name:        aws.String(g.Name),
 	})
 	if err != nil {
-		fmt.Printf(err.Error())
+		return nil, nil, err
 	}
 
-	time.Sleep(6 * time.Second)
-
-	rootID := GetAPIParentID(res.Id, sess)
+	rootID, err := p.GetAPIParentID(ctx, res.Id)
+	if err != nil {
+		return res, nil, fmt.Errorf("looking up root resource of REST API %s: %w", aws.StringValue(res.Id), err)
+	}
+	p.record(func(s *state.State) {
+		gw := recordedGateway(s, g.Name)
+		gw.RestAPIID, gw.RootResourceID = aws.StringValue(res.Id), aws.StringValue(rootID)
+	})
 
-	return res, rootID, err
+	return res, rootID, nil
 }
 
-// DeleteRole will delete the given Role resources from the IAM console
-func DeleteRole(roleName string, sess *session.Session) (*iam.DeleteRoleOutput, error) {
-	svc := iam.New(sess)
+// DeleteRole will delete the given Role resources from the IAM console, it
+// and waits until it can no longer be found
+func (p *Provisioner) DeleteRole(ctx context.Context, roleName string) (*iam.DeleteRoleOutput, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	res, err := svc.DeleteRole(&iam.DeleteRoleInput{
+	res, err := p.IAMSvc.DeleteRoleWithContext(ctx, &iam.DeleteRoleInput{
 		RoleName: aws.String(roleName),
 	})
+	if err == nil || isNotFound(err) {
+		p.record(func(s *state.State) { delete(s.Roles, roleName) })
+	}
 	if err != nil {
-		fmt.Println(err.Error())
+		return res, err
 	}
-	time.Sleep(6 * time.Second)
-	return res, err
+	if err := p.waitForRoleDeleted(ctx, roleName); err != nil {
+		return res, fmt.Errorf("waiting for role %s to be deleted: %w", roleName, err)
+	}
+	return res, nil
 }
 
-// DeleteAttachedPolicy will delete the given policies from the nominated role
-func DeleteAttachedPolicy(policy string, roleName string, sess *session.Session) (*iam.DeleteRolePolicyOutput, error) {
-	svc := iam.New(sess)
+// DeleteAttachedPolicy will detach the given managed policy from the nominated role, the policy itself is
+// left in place as it may be attached elsewhere
+func (p *Provisioner) DeleteAttachedPolicy(ctx context.Context, ap AttachPolicyInput) (*iam.DetachRolePolicyOutput, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	res, err := svc.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
-		RoleName:   aws.String(policy),
-		PolicyName: aws.String(roleName),
+	arn := policyArn(ap)
+	res, err := p.IAMSvc.DetachRolePolicyWithContext(ctx, &iam.DetachRolePolicyInput{
+		PolicyArn: aws.String(arn),
+		RoleName:  aws.String(ap.RoleName),
+	})
+	if (err == nil || isNotFound(err)) && p.State != nil && p.State.Roles[ap.RoleName] != nil {
+		p.record(func(s *state.State) { s.Roles[ap.RoleName].Policies = remove(s.Roles[ap.RoleName].Policies, arn) })
+	}
+	if err != nil {
+		return res, err
+	}
+	err = p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			attached, err := p.policyAttached(ctx, ap.RoleName, arn)
+			return !attached, err
+		})
 	})
 	if err != nil {
-		fmt.Printf(err.Error())
+		return res, fmt.Errorf("waiting for policy %s to detach from role %s: %w", arn, ap.RoleName, err)
 	}
-	time.Sleep(6 * time.Second)
-	return res, err
+	return res, nil
 }
 
-// DeleteLambda deletes the given function by name
-func DeleteLambda(funcName string, sess *session.Session) (*lambda.DeleteFunctionOutput, error) {
-	svc := lambda.New(sess)
+// attachedPolicies returns the ARNs of the managed policies recorded in state as attached to the role and of
+// those IAM lists as attached, a role which no longer exists has none
+func (p *Provisioner) attachedPolicies(ctx context.Context, roleName string) ([]string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	res, err := svc.DeleteFunction(&lambda.DeleteFunctionInput{
+	var arns []string
+	if p.State != nil && p.State.Roles[roleName] != nil {
+		arns = append(arns, p.State.Roles[roleName].Policies...)
+	}
+	err := p.IAMSvc.ListAttachedRolePoliciesPagesWithContext(ctx, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	}, func(page *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+		for _, policy := range page.AttachedPolicies {
+			if arn := aws.StringValue(policy.PolicyArn); !contains(arns, arn) {
+				arns = append(arns, arn)
+			}
+		}
+		return true
+	})
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("listing the policies attached to role %s: %w", roleName, err)
+	}
+	return arns, nil
+}
+
+// policyArns returns the ARN of each of the policies, as AttachPolicy resolves them for a role of the service
+func policyArns(policies []string, service string) []string {
+	var arns []string
+	for _, policy := range policies {
+		arns = append(arns, policyArn(AttachPolicyInput{Policy: policy, Service: service}))
+	}
+	return arns
+}
+
+// ErrFunctionNotFound is returned by DeleteLambda when the function does not exist
+var ErrFunctionNotFound = errors.New("function not found")
+
+// DeleteLambda deletes the given function by name and waits until it can no longer be found. For a function
+// attached to a VPC it also waits for Lambda to release the function's network interfaces, bounded by
+// ENITimeout rather than Timeout as this can take many minutes, and with securityGroups then deletes the
+// security groups the function was in. The schedules recorded against the function are deleted first, as their
+// rules would otherwise go on targeting a function which no longer exists
+func (p *Provisioner) DeleteLambda(ctx context.Context, funcName string, securityGroups bool) (*lambda.DeleteFunctionOutput, error) {
+	return p.deleteLambda(ctx, funcName, true, securityGroups)
+}
+
+// deleteLambda is DeleteLambda, only waiting for the network interfaces of a VPC function to be released with
+// waitENIs, which callers leaving the security groups in place can do without
+func (p *Provisioner) deleteLambda(parent context.Context, funcName string, waitENIs, securityGroups bool) (*lambda.DeleteFunctionOutput, error) {
+	ctx, cancel := p.withTimeout(parent)
+	defer cancel()
+
+	for _, rule := range p.recordedSchedules(funcName) {
+		if err := p.DeleteSchedule(ctx, rule); err != nil {
+			return nil, err
+		}
+	}
+
+	vpc, err := p.FunctionVPC(ctx, funcName)
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("looking up function %s: %w", funcName, err)
+	}
+
+	res, err := p.LambdaSvc.DeleteFunctionWithContext(ctx, &lambda.DeleteFunctionInput{
 		FunctionName: aws.String(funcName),
 	})
+	if err == nil || isNotFound(err) {
+		p.record(func(s *state.State) { delete(s.Functions, funcName) })
+	}
+	if isNotFound(err) {
+		return res, fmt.Errorf("%w with name %s", ErrFunctionNotFound, funcName)
+	}
 	if err != nil {
-		fmt.Printf(err.Error())
+		return res, err
 	}
-	time.Sleep(6 * time.Second)
-	return res, err
+	if err := p.waitForFunctionDeleted(ctx, funcName); err != nil {
+		return res, fmt.Errorf("waiting for function %s to be deleted: %w", funcName, err)
+	}
+	if vpc == nil || !waitENIs {
+		return res, nil
+	}
+	eniCtx, eniCancel := p.withENITimeout(parent)
+	defer eniCancel()
+	if err := p.waitForENIsReleased(eniCtx, funcName, vpc); err != nil {
+		return res, fmt.Errorf("waiting for the network interfaces of function %s to be released: %w", funcName, err)
+	}
+	if securityGroups {
+		if err := p.DeleteSecurityGroups(parent, aws.StringValueSlice(vpc.SecurityGroupIds)); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
 }
 
-// DeleteRestAPI deletes the given Rest API, use GetRestApi to see available APIs for deletion
-func DeleteRestAPI(name string, sess *session.Session) (*apigateway.DeleteRestApiOutput, error) {
-	svc := apigateway.New(sess)
+// DeleteRestAPI deletes the Rest API with the given ID, use RestAPIID or ResolveRestAPI to find the ID of a
+// gateway by name
+func (p *Provisioner) DeleteRestAPI(ctx context.Context, id string) (*apigateway.DeleteRestApiOutput, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	res, err := svc.DeleteRestApi(&apigateway.DeleteRestApiInput{
-		RestApiId: aws.String(name),
+	res, err := p.APIGatewaySvc.DeleteRestApiWithContext(ctx, &apigateway.DeleteRestApiInput{
+		RestApiId: aws.String(id),
 	})
+	if err == nil || isNotFound(err) {
+		p.record(func(s *state.State) {
+			if gw, _, ok := s.GatewayByID(id); ok {
+				delete(s.Gateways, gw)
+			}
+		})
+	}
 	if err != nil {
-		fmt.Printf(err.Error())
+		return res, err
 	}
-	time.Sleep(6 * time.Second)
-	return res, err
+	if err := p.waitForRestAPIDeleted(ctx, id); err != nil {
+		return res, fmt.Errorf("waiting for REST API %s to be deleted: %w", id, err)
+	}
+	return res, nil
 }
 
-// GetAPIParentID gets the Parent ID of the newly created rest api in order to create the new resource
-func GetAPIParentID(apiID *string, sess *session.Session) *string {
-	svc := apigateway.New(sess)
-
-	res, err := svc.GetResources(&apigateway.GetResourcesInput{
+// GetAPIParentID gets the ID of the root resource of the newly created rest api in order to create the new
+// resource
+func (p *Provisioner) GetAPIParentID(ctx context.Context, apiID *string) (*string, error) {
+	res, err := p.APIGatewaySvc.GetResourcesWithContext(ctx, &apigateway.GetResourcesInput{
 		RestApiId: apiID,
 	})
 	if err != nil {
-		fmt.Printf(err.Error())
+		return nil, err
 	}
-	var ID *string
 	for _, item := range res.Items {
-		ID = item.Id
-		if aws.StringValue(ID) == "" {
-			os.Exit(1)
+		if aws.StringValue(item.Path) == "/" {
+			return item.Id, nil
 		}
 	}
-	return ID
+	return nil, fmt.Errorf("REST API %s has no root resource", aws.StringValue(apiID))
 }
 
-// ConfigureAPIEndpoint conducts the necessary steps to make the API reachable
-func ConfigureAPIEndpoint(rootID *string, api *string, name *string, funcName string, sess *session.Session) {
-	svc := apigateway.New(sess)
+// ConfigureAPIEndpoint conducts the necessary steps to make the API reachable, and waits until the prod stage
+// is serving the new deployment. It stops at the first step which fails and undoes the steps already completed,
+// so a failed setup never leaves a half-wired API behind. funcName may be qualified with an alias, as in
+// "name:alias", to invoke and permit the alias rather than the function
+func (p *Provisioner) ConfigureAPIEndpoint(ctx context.Context, rootID *string, api *string, name *string, funcName string) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	svc := p.APIGatewaySvc
+	var undo undoStack
+	defer func() {
+		if err != nil {
+			err = undo.run(p, err)
+		}
+	}()
 
-	res, err := svc.CreateResource(&apigateway.CreateResourceInput{
+	res, err := svc.CreateResourceWithContext(ctx, &apigateway.CreateResourceInput{
 		RestApiId: api,
 		ParentId:  rootID,
 		PathPart:  name,
 	})
 	if err != nil {
-		fmt.Printf(err.Error())
-	} else {
-		fmt.Println("Adding resource: ", res)
+		return fmt.Errorf("creating resource: %w", err)
 	}
-	time.Sleep(6 * time.Second)
-
+	fmt.Println("Adding resource: ", res)
 	resID := res.Id
+	undo.push("deleting resource", func(ctx context.Context) error {
+		_, err := svc.DeleteResourceWithContext(ctx, &apigateway.DeleteResourceInput{RestApiId: api, ResourceId: resID})
+		return err
+	})
 
-	mth, err := svc.PutMethod(&apigateway.PutMethodInput{
+	mth, err := svc.PutMethodWithContext(ctx, &apigateway.PutMethodInput{
 		AuthorizationType: aws.String("None"),
 		HttpMethod:        aws.String("POST"),
 		RestApiId:         api,
 		ResourceId:        resID,
 	})
 	if err != nil {
-		fmt.Printf(err.Error())
-	} else {
-		fmt.Println("Adding method: ", mth)
+		return fmt.Errorf("adding method: %w", err)
 	}
+	fmt.Println("Adding method: ", mth)
+	undo.push("deleting method", func(ctx context.Context) error {
+		_, err := svc.DeleteMethodWithContext(ctx, &apigateway.DeleteMethodInput{
+			RestApiId: api, ResourceId: resID, HttpMethod: aws.String("POST"),
+		})
+		return err
+	})
 
-	functionArn, err := GetLambdaFunctionArn(funcName, sess)
+	functionArn, err := p.GetLambdaFunctionArn(ctx, funcName)
+	if err != nil {
+		return fmt.Errorf("looking up function %s: %w", funcName, err)
+	}
 
-	intg, err := svc.PutIntegration(&apigateway.PutIntegrationInput{
+	intg, err := svc.PutIntegrationWithContext(ctx, &apigateway.PutIntegrationInput{
 		ResourceId:            resID,
 		RestApiId:             api,
 		HttpMethod:            aws.String("POST"),
 		IntegrationHttpMethod: aws.String("POST"),
 		Type:                  aws.String("AWS"),
-		Uri:                   aws.String("arn:aws:apigateway:" + aws.StringValue(sess.Config.Region) + ":lambda:path/2015-03-31/functions/" + aws.StringValue(functionArn) + "/invocations"),
+		Uri:                   aws.String("arn:aws:apigateway:" + p.Region + ":lambda:path/2015-03-31/functions/" + aws.StringValue(functionArn) + "/invocations"),
 	})
 	if err != nil {
-		fmt.Println(err.Error())
-	} else {
-		fmt.Println("Adding integration: ", intg)
+		return fmt.Errorf("adding integration: %w", err)
 	}
+	fmt.Println("Adding integration: ", intg)
+	undo.push("deleting integration", func(ctx context.Context) error {
+		_, err := svc.DeleteIntegrationWithContext(ctx, &apigateway.DeleteIntegrationInput{
+			RestApiId: api, ResourceId: resID, HttpMethod: aws.String("POST"),
+		})
+		return err
+	})
 
 	var str = "Empty"
 
 	respModel := make(map[string]*string, 1)
 	respModel["application/json"] = &str
 
-	mthRes, err := svc.PutMethodResponse(&apigateway.PutMethodResponseInput{
+	mthRes, err := svc.PutMethodResponseWithContext(ctx, &apigateway.PutMethodResponseInput{
 		HttpMethod:     aws.String("POST"),
 		RestApiId:      api,
 		ResourceId:     resID,
@@ -299,15 +880,20 @@ func ConfigureAPIEndpoint(rootID *string, api *string, name *string, funcName st
 		StatusCode:     aws.String("200"),
 	})
 	if err != nil {
-		fmt.Println(err.Error())
-	} else {
-		fmt.Println("Adding method response: ", mthRes)
+		return fmt.Errorf("adding method response: %w", err)
 	}
+	fmt.Println("Adding method response: ", mthRes)
+	undo.push("deleting method response", func(ctx context.Context) error {
+		_, err := svc.DeleteMethodResponseWithContext(ctx, &apigateway.DeleteMethodResponseInput{
+			RestApiId: api, ResourceId: resID, HttpMethod: aws.String("POST"), StatusCode: aws.String("200"),
+		})
+		return err
+	})
 
 	str = ""
 	respModel["application/json"] = &str
 
-	intRes, err := svc.PutIntegrationResponse(&apigateway.PutIntegrationResponseInput{
+	intRes, err := svc.PutIntegrationResponseWithContext(ctx, &apigateway.PutIntegrationResponseInput{
 		HttpMethod:        aws.String("POST"),
 		RestApiId:         api,
 		ResourceId:        resID,
@@ -315,94 +901,207 @@ func ConfigureAPIEndpoint(rootID *string, api *string, name *string, funcName st
 		StatusCode:        aws.String("200"),
 	})
 	if err != nil {
-		fmt.Println(err.Error())
-	} else {
-		fmt.Println("Adding integration response: ", intRes)
+		return fmt.Errorf("adding integration response: %w", err)
 	}
+	fmt.Println("Adding integration response: ", intRes)
+	undo.push("deleting integration response", func(ctx context.Context) error {
+		_, err := svc.DeleteIntegrationResponseWithContext(ctx, &apigateway.DeleteIntegrationResponseInput{
+			RestApiId: api, ResourceId: resID, HttpMethod: aws.String("POST"), StatusCode: aws.String("200"),
+		})
+		return err
+	})
 
-	dep, err := svc.CreateDeployment(&apigateway.CreateDeploymentInput{
-		RestApiId: resID,
+	// The deployment creates the prod stage, or repoints it if it already exists, so undoing it means putting
+	// back the stage as it was before deleting the deployment
+	var previous *string
+	stage, err := svc.GetStageWithContext(ctx, &apigateway.GetStageInput{RestApiId: api, StageName: aws.String("prod")})
+	if err == nil {
+		previous = stage.DeploymentId
+	} else if !isNotFound(err) {
+		return fmt.Errorf("looking up stage prod: %w", err)
+	}
+
+	dep, err := svc.CreateDeploymentWithContext(ctx, &apigateway.CreateDeploymentInput{
+		RestApiId: api,
 		StageName: aws.String("prod"),
 	})
 	if err != nil {
-		fmt.Println(err.Error())
-	} else {
-		fmt.Println("Creating deployment for API Gateway: ", dep)
+		return fmt.Errorf("creating deployment: %w", err)
 	}
+	fmt.Println("Creating deployment for API Gateway: ", dep)
+	undo.push("deleting deployment", func(ctx context.Context) error {
+		var err error
+		if previous == nil {
+			_, err = svc.DeleteStageWithContext(ctx, &apigateway.DeleteStageInput{RestApiId: api, StageName: aws.String("prod")})
+		} else {
+			_, err = svc.UpdateStageWithContext(ctx, &apigateway.UpdateStageInput{
+				RestApiId: api,
+				StageName: aws.String("prod"),
+				PatchOperations: []*apigateway.PatchOperation{{
+					Op: aws.String(apigateway.OpReplace), Path: aws.String("/deploymentId"), Value: previous,
+				}},
+			})
+		}
+		if err != nil {
+			return err
+		}
+		_, err = svc.DeleteDeploymentWithContext(ctx, &apigateway.DeleteDeploymentInput{RestApiId: api, DeploymentId: dep.Id})
+		return err
+	})
 
-	time.Sleep(6 * time.Second)
+	if err := p.waitForDeployment(ctx, aws.StringValue(api), "prod", aws.StringValue(dep.Id)); err != nil {
+		return fmt.Errorf("waiting for deployment %s: %w", aws.StringValue(dep.Id), err)
+	}
 
 	var (
 		pathPrefix = "arn:aws:execute-api:"
 		pathSuffix = "/*/POST/" + aws.StringValue(res.PathPart)
-		SourceArn  = aws.String(pathPrefix + aws.StringValue(sess.Config.Region) + ":" + os.Getenv("account") + ":" + aws.StringValue(resID) + pathSuffix)
+		SourceArn  = aws.String(pathPrefix + p.Region + ":" + p.Account + ":" + aws.StringValue(api) + pathSuffix)
 	)
 
-	err = AddLambdaPermissions(funcName, SourceArn, sess)
+	// only the statements added here are undone, those reused from an earlier run are left for the gateway
+	// already relying on them
+	added, err := p.AddLambdaPermissions(ctx, funcName, SourceArn)
+	for _, id := range added {
+		id := id
+		undo.push("removing permission "+id, func(ctx context.Context) error {
+			return p.removeLambdaPermission(ctx, funcName, id)
+		})
+	}
 	if err != nil {
-		fmt.Println(err.Error())
+		return fmt.Errorf("adding permissions to function %s: %w", funcName, err)
 	}
+
+	p.record(func(s *state.State) {
+		gw := recordedGateway(s, aws.StringValue(name))
+		gw.RestAPIID, gw.ResourceID, gw.DeploymentID = aws.StringValue(api), aws.StringValue(resID), aws.StringValue(dep.Id)
+		gw.Stage, gw.FunctionName = "prod", funcName
+	})
+	return nil
 }
 
 // GetLambdaFunctionArn is a way to retrieve the Lambda ARN
-func GetLambdaFunctionArn(funcName string, sess *session.Session) (*string, error) {
-	svc := lambda.New(sess)
-
-	function, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+func (p *Provisioner) GetLambdaFunctionArn(ctx context.Context, funcName string) (*string, error) {
+	function, err := p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
 		FunctionName: aws.String(funcName),
 	})
 	if err != nil {
-		fmt.Println(err.Error())
+		return nil, err
 	}
-	return function.FunctionArn, err
+	return function.FunctionArn, nil
 }
 
 // AddLambdaPermissions allows us to add permissions to invoke the function through the Gateway
-// this was a bit 'hacky' but I couldn't find any other way to do it.
-func AddLambdaPermissions(funcName string, path *string, sess *session.Session) error {
-	svc := lambda.New(sess)
-
-	perms, err := svc.AddPermission(&lambda.AddPermissionInput{
-		FunctionName: aws.String(funcName),
-		StatementId:  aws.String("apigateway-test-2"),
-		Action:       aws.String("lambda:InvokeFunction"),
-		Principal:    aws.String("apigateway.amazonaws.com"),
-		SourceArn:    path,
-	})
-	if err != nil {
-		fmt.Println(err.Error())
-	} else {
+// this was a bit 'hacky' but I couldn't find any other way to do it. It returns the statement IDs it added,
+// also when it fails part way. A statement which already exists for the same path is reused rather than
+// added, one allowing another path belongs to another gateway and is an error, leaving it in place
+func (p *Provisioner) AddLambdaPermissions(ctx context.Context, funcName string, path *string) ([]string, error) {
+	var added []string
+	for _, id := range gatewayStatementIDs {
+		perms, err := p.LambdaSvc.AddPermissionWithContext(ctx, &lambda.AddPermissionInput{
+			FunctionName: aws.String(funcName),
+			StatementId:  aws.String(id),
+			Action:       aws.String("lambda:InvokeFunction"),
+			Principal:    aws.String("apigateway.amazonaws.com"),
+			SourceArn:    path,
+		})
+		if isAlreadyExists(err) {
+			source, serr := p.permissionSource(ctx, funcName, id)
+			if serr != nil {
+				return added, fmt.Errorf("looking up permission %s: %w", id, serr)
+			}
+			if source != aws.StringValue(path) {
+				return added, fmt.Errorf("permission %s already allows %s to invoke the function: %w", id, source, err)
+			}
+			fmt.Println("Reusing permission of Lambda: ", id)
+			continue
+		}
+		if err != nil {
+			return added, err
+		}
 		fmt.Println("Adding permissions to Lambda: ", perms)
+		added = append(added, id)
+		p.recordPermission(funcName, id)
 	}
+	return added, nil
+}
 
-	perms1, err := svc.AddPermission(&lambda.AddPermissionInput{
-		FunctionName: aws.String(funcName),
-		StatementId:  aws.String("apigateway-prod-2"),
-		Action:       aws.String("lambda:InvokeFunction"),
-		Principal:    aws.String("apigateway.amazonaws.com"),
-		SourceArn:    path,
-	})
+// permissionSource returns the source ARN the statement of the function's policy allows to invoke it
+func (p *Provisioner) permissionSource(ctx context.Context, funcName, id string) (string, error) {
+	res, err := p.LambdaSvc.GetPolicyWithContext(ctx, &lambda.GetPolicyInput{FunctionName: aws.String(funcName)})
 	if err != nil {
-		fmt.Println(err.Error())
-	} else {
-		fmt.Println("Adding permissions to Lambda: ", perms1)
+		return "", err
 	}
-
-	return err
+	var policy struct {
+		Statement []struct {
+			Sid       string
+			Condition struct {
+				ArnLike map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(aws.StringValue(res.Policy)), &policy); err != nil {
+		return "", err
+	}
+	for _, st := range policy.Statement {
+		if st.Sid == id {
+			return st.Condition.ArnLike["AWS:SourceArn"], nil
+		}
+	}
+	return "", nil
 }
 
-// CreateAllResources is used to make all of the above resources, similarly to a stack, rather than having to
-// have a switch statement to trigger each, more logic to be added in the lmabda itself for this
-func CreateAllResources() {
-
+// RemoveLambdaPermissions removes the permissions added by AddLambdaPermissions, it returns the statement IDs
+// which were actually removed so callers can tell them apart from those already gone
+func (p *Provisioner) RemoveLambdaPermissions(ctx context.Context, funcName string) ([]string, error) {
+	var removed []string
+	for _, id := range gatewayStatementIDs {
+		err := p.removeLambdaPermission(ctx, funcName, id)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, id)
+	}
+	return removed, nil
 }
 
-// DeleteAllResources is the same as the above, but a teardown instead of setting up
-func DeleteAllResources() {
-
+// removeLambdaPermission removes a single statement from the function's policy and forgets it
+func (p *Provisioner) removeLambdaPermission(ctx context.Context, funcName, id string) error {
+	_, err := p.LambdaSvc.RemovePermissionWithContext(ctx, &lambda.RemovePermissionInput{
+		FunctionName: aws.String(funcName),
+		StatementId:  aws.String(id),
+	})
+	if err != nil {
+		return err
+	}
+	if name := baseName(funcName); p.State != nil && p.State.Functions[name] != nil {
+		p.record(func(s *state.State) {
+			s.Functions[name].Permissions = remove(s.Functions[name].Permissions, id)
+		})
+	}
+	return nil
 }
 
-// TestAPI is used to test the setup of the resources
-func TestAPI() {
-
+// isNotFound reports whether err is the not found error of any of the services used by the Provisioner
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	switch aerr.Code() {
+	case iam.ErrCodeNoSuchEntityException,
+		lambda.ErrCodeResourceNotFoundException,
+		lambda.ErrCodeProvisionedConcurrencyConfigNotFoundException,
+		apigateway.ErrCodeNotFoundException,
+		s3.ErrCodeNoSuchBucket,
+		s3.ErrCodeNoSuchKey,
+		// S3 HEAD responses have no body, so their errors only carry the status
+		"NotFound",
+		"InvalidGroup.NotFound":
+		return true
+	}
+	return false
 }
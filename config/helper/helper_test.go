@@ -1,24 +1,32 @@
 package helper
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
 	"log"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
-	"github.com/VariableExp0rt/lambda-and-fun/config/session"
+	"github.com/VariableExp0rt/lambda-and-fun/config/deploy"
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
 )
 
 func TestCreateRoles(t *testing.T) {
-	s := session.NewSession("")
+	p, _, _, _ := newFakeProvisioner()
 
-	r, err := CreateRole(Role{
+	r, err := p.CreateRole(context.Background(), Role{
 		RoleName:    "Testing1",
 		Description: "My testing role",
 		Service:     "ec2.amazonaws.com",
-	}, s)
+	})
 	if err != nil {
-		log.Printf(err.Error())
+		t.Fatalf("CreateRoles failed: %v", err)
 	}
 
 	want := "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Principal\": {\"Service\": \"" + "ec2.amazonaws.com" + "\"},\"Action\": \"sts:AssumeRole\"}]}"
@@ -31,9 +39,10 @@ func TestCreateRoles(t *testing.T) {
 }
 
 func TestDeleteRoles(t *testing.T) {
-	s := session.NewSession("")
+	p, f, _, _ := newFakeProvisioner()
+	f.roles["Testing1"] = &iam.Role{RoleName: aws.String("Testing1")}
 
-	r, _ := DeleteRole("Testing1", s)
+	r, _ := p.DeleteRole(context.Background(), "Testing1")
 
 	want := string(`{
 
@@ -45,4 +54,177 @@ func TestDeleteRoles(t *testing.T) {
 	} else {
 		log.Printf("DeleteRoles successful, expected %v, got %v", want, got)
 	}
+	if len(f.deleted) != 1 || f.deleted[0] != "Testing1" {
+		t.Errorf("DeleteRoles failed, expected role Testing1 to be deleted, got %v", f.deleted)
+	}
+}
+
+func TestPolicyArn(t *testing.T) {
+	tests := []struct {
+		in   AttachPolicyInput
+		want string
+	}{
+		{AttachPolicyInput{Policy: "service-role/AWSLambdaBasicExecutionRole", Service: "lambda.amazonaws.com"}, "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"},
+		{AttachPolicyInput{Policy: "lambda-policy", Service: "lambda.amazonaws.com"}, "arn:aws:iam::aws:policy/service-role/lambda-policy"},
+		{AttachPolicyInput{Policy: "AmazonEKSClusterPolicy", Service: "eks.amazonaws.com"}, "arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"},
+		{AttachPolicyInput{Policy: "arn:aws:iam::123456789012:policy/custom", Service: "lambda.amazonaws.com"}, "arn:aws:iam::123456789012:policy/custom"},
+	}
+	for _, tt := range tests {
+		if got := policyArn(tt.in); got != tt.want {
+			t.Errorf("policyArn(%+v) failed, expected %v, got %v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestStateRecordsRole(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, _, _, _ := newFakeProvisioner()
+	if p.State, err = state.Load(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := p.CreateRole(ctx, Role{RoleName: "Testing1", Service: "lambda.amazonaws.com"}); err != nil {
+		t.Fatalf("CreateRole failed: %v", err)
+	}
+	saved, err := state.Load(p.State.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := saved.Roles["Testing1"]; got == nil || got.Arn != "arn:aws:iam::123456789012:role/Testing1" {
+		t.Errorf("CreateRole failed to record the role ARN, got %+v", got)
+	}
+
+	if _, err := p.DeleteRole(ctx, "Testing1"); err != nil {
+		t.Fatalf("DeleteRole failed: %v", err)
+	}
+	if saved, _ = state.Load(p.State.Path()); saved.Roles["Testing1"] != nil {
+		t.Errorf("DeleteRole failed to forget the role, got %+v", saved.Roles["Testing1"])
+	}
+}
+
+func TestUpdateLambda(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), Handler: aws.String("main"), Runtime: aws.String("go1.x"),
+	}
+	fl.code["stack-action"] = []byte("old code")
+	fl.permissions["stack-action"] = []string{gatewayStatementIDs[0]}
+
+	code, pkg := writePackage(t, "main")
+	res, err := p.UpdateLambda(ctx, Lambda{FunctionName: "stack-action", Code: code}, false, "live")
+	if err != nil {
+		t.Fatalf("UpdateLambda failed: %v", err)
+	}
+	if string(fl.code["stack-action"]) != string(pkg) || aws.StringValue(fl.functions["stack-action"].Handler) != "main" {
+		t.Errorf("UpdateLambda failed, expected only the code to change, got handler %q",
+			aws.StringValue(fl.functions["stack-action"].Handler))
+	}
+	if aws.StringValue(res.Version) != "1" || fl.aliases["stack-action:live"] != "1" {
+		t.Errorf("UpdateLambda failed, expected alias live on version 1, got %v on %v", fl.aliases["stack-action:live"], aws.StringValue(res.Version))
+	}
+	if len(fl.permissions["stack-action"]) != 1 {
+		t.Errorf("UpdateLambda failed, expected the permissions to be kept, got %v", fl.permissions["stack-action"])
+	}
+
+	if _, err := p.UpdateLambda(ctx, Lambda{FunctionName: "stack-action", Handler: "bootstrap"}, false, "live"); err != nil {
+		t.Fatalf("UpdateLambda failed: %v", err)
+	}
+	if aws.StringValue(fl.functions["stack-action"].Handler) != "bootstrap" || fl.aliases["stack-action:live"] != "2" {
+		t.Errorf("UpdateLambda failed, expected handler bootstrap and alias live moved to version 2, got %q on %v",
+			aws.StringValue(fl.functions["stack-action"].Handler), fl.aliases["stack-action:live"])
+	}
+
+	if _, err := p.UpdateLambda(ctx, Lambda{FunctionName: "missing", Handler: "main"}, true, ""); !isNotFound(err) {
+		t.Errorf("UpdateLambda failed, expected not found for a missing function, got %v", err)
+	}
+}
+
+func TestCreateLambdaRejectsInvalidPackage(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	l := Lambda{FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/r"}
+
+	for _, code := range []string{
+		filepath.Join(os.TempDir(), "missing", "deployment.zip"),
+		writeManifest(t, "deployment.zip", "not a zip"),
+	} {
+		l.Code = code
+		if _, err := p.CreateLambda(ctx, l); err == nil {
+			t.Errorf("CreateLambda with %s expected an error", code)
+		}
+	}
+
+	l.Code, _ = writePackage(t, "bootstrap")
+	if _, err := p.CreateLambda(ctx, l); !errors.Is(err, deploy.ErrInvalidPackage) {
+		t.Errorf("CreateLambda failed, expected %v for a package without the handler, got %v", deploy.ErrInvalidPackage, err)
+	}
+	if len(fl.functions) != 0 {
+		t.Errorf("CreateLambda failed, expected nothing to be created, got %v", fl.functions)
+	}
+}
+
+func TestLambdaRuntimeConfiguration(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	code, _ := writePackage(t, "main")
+
+	l := Lambda{
+		FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/r", Code: code,
+		MemorySize: 512, Timeout: 300, Environment: map[string]string{"STAGE": "prod"}, Tags: map[string]string{"team": "platform"},
+	}
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	fn := fl.functions["stack-action"]
+	if aws.Int64Value(fn.MemorySize) != 512 || aws.Int64Value(fn.Timeout) != 300 || aws.StringValue(fn.Environment.Variables["STAGE"]) != "prod" {
+		t.Errorf("CreateLambda failed, expected memory 512, timeout 300 and STAGE=prod, got %v", fn)
+	}
+	if aws.StringValue(fl.tags["stack-action"]["team"]) != "platform" {
+		t.Errorf("CreateLambda failed, expected the team tag, got %v", fl.tags["stack-action"])
+	}
+
+	_, err := p.UpdateLambda(ctx, Lambda{FunctionName: "stack-action", Timeout: 600, Tags: map[string]string{"owner": "ops"}}, false, "")
+	if err != nil {
+		t.Fatalf("UpdateLambda failed: %v", err)
+	}
+	if aws.Int64Value(fn.Timeout) != 600 || aws.Int64Value(fn.MemorySize) != 512 || aws.StringValue(fn.Environment.Variables["STAGE"]) != "prod" {
+		t.Errorf("UpdateLambda failed, expected only the timeout to change, got %v", fn)
+	}
+	if len(fl.tags["stack-action"]) != 2 {
+		t.Errorf("UpdateLambda failed, expected the owner tag added, got %v", fl.tags["stack-action"])
+	}
+	if (Lambda{FunctionName: "stack-action"}).configuration() != nil {
+		t.Errorf("configuration failed, expected nil when nothing is set")
+	}
+}
+
+func TestCreateLambdaProvidedRuntime(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	l := Lambda{FunctionName: "stack-action", Runtime: "provided.al2", Role: "arn:aws:iam::123456789012:role/r"}
+
+	l.Code, _ = writePackage(t, "main")
+	if _, err := p.CreateLambda(ctx, l); !errors.Is(err, deploy.ErrInvalidPackage) {
+		t.Errorf("CreateLambda failed, expected %v for a package without bootstrap, got %v", deploy.ErrInvalidPackage, err)
+	}
+
+	l.Code, _ = writePackage(t, "bootstrap")
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	if got := aws.StringValue(fl.functions["stack-action"].Handler); got != "bootstrap" {
+		t.Errorf("CreateLambda failed, expected handler bootstrap, got %q", got)
+	}
+
+	l.FunctionName, l.Runtime = "stack-script", "python3.8"
+	if _, err := p.CreateLambda(ctx, l); err == nil {
+		t.Errorf("CreateLambda expected an error for a python function without a handler")
+	}
 }
@@ -0,0 +1,138 @@
+package helper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// invokeHeadroom is how much longer than its own timeout a synchronous invocation of a function is waited for,
+// leaving time for a cold start and the response to come back
+const invokeHeadroom = 30 * time.Second
+
+// InvokeResult is the outcome of Invoke. FunctionError is set when the function failed, in which case Payload
+// holds the error it returned. Log is the decoded tail of the execution log, only returned for synchronous
+// invocations
+type InvokeResult struct {
+	StatusCode      int64
+	ExecutedVersion string
+	FunctionError   string
+	Log             string
+	Payload         []byte
+}
+
+// OK reports whether the invocation was accepted and the function did not fail
+func (r *InvokeResult) OK() bool {
+	return r.StatusCode/100 == 2 && r.FunctionError == ""
+}
+
+// APIGatewayProxyRequest is the event API Gateway sends a function through a Lambda proxy integration, with the
+// same JSON shape as events.APIGatewayProxyRequest of aws-lambda-go so handlers written against it can be
+// invoked directly
+type APIGatewayProxyRequest struct {
+	Resource              string                        `json:"resource"`
+	Path                  string                        `json:"path"`
+	HTTPMethod            string                        `json:"httpMethod"`
+	Headers               map[string]string             `json:"headers"`
+	QueryStringParameters map[string]string             `json:"queryStringParameters"`
+	PathParameters        map[string]string             `json:"pathParameters"`
+	StageVariables        map[string]string             `json:"stageVariables"`
+	RequestContext        APIGatewayProxyRequestContext `json:"requestContext"`
+	Body                  string                        `json:"body"`
+	IsBase64Encoded       bool                          `json:"isBase64Encoded"`
+}
+
+// APIGatewayProxyRequestContext is the part of the request context of a proxy event handlers commonly read
+type APIGatewayProxyRequestContext struct {
+	AccountID  string `json:"accountId"`
+	ResourceID string `json:"resourceId"`
+	Stage      string `json:"stage"`
+	RequestID  string `json:"requestId"`
+	HTTPMethod string `json:"httpMethod"`
+	Path       string `json:"path"`
+	APIID      string `json:"apiId"`
+}
+
+// ProxyRequest wraps body in the event a POST of it to path through a proxy integration on the prod stage
+// would send the function
+func (p *Provisioner) ProxyRequest(path, body string) ([]byte, error) {
+	return json.Marshal(APIGatewayProxyRequest{
+		Resource:   path,
+		Path:       path,
+		HTTPMethod: http.MethodPost,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		RequestContext: APIGatewayProxyRequestContext{
+			AccountID:  p.Account,
+			Stage:      "prod",
+			RequestID:  "my-app-invoke",
+			HTTPMethod: http.MethodPost,
+			Path:       "/prod" + path,
+		},
+		Body: body,
+	})
+}
+
+// Invoke calls the function, which may be qualified with a version or alias as in "name:alias", with payload
+// and waits for its response. With async the invocation is only queued, Lambda returns 202 and neither a
+// payload nor a log. A synchronous invocation is waited for as long as the function may run, rather than the
+// provisioner's timeout, so a slow function is not cut short
+func (p *Provisioner) Invoke(ctx context.Context, funcName string, payload []byte, async bool) (*InvokeResult, error) {
+	var cancel context.CancelFunc
+	if async {
+		ctx, cancel = p.withTimeout(ctx)
+	} else {
+		ctx, cancel = p.invokeTimeout(ctx, funcName)
+	}
+	defer cancel()
+
+	in := &lambda.InvokeInput{
+		FunctionName:   aws.String(funcName),
+		Payload:        payload,
+		InvocationType: aws.String(lambda.InvocationTypeRequestResponse),
+		LogType:        aws.String(lambda.LogTypeTail),
+	}
+	if async {
+		in.InvocationType, in.LogType = aws.String(lambda.InvocationTypeEvent), nil
+	}
+	res, err := p.LambdaSvc.InvokeWithContext(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("invoking function %s: %w", funcName, err)
+	}
+
+	result := &InvokeResult{
+		StatusCode:      aws.Int64Value(res.StatusCode),
+		ExecutedVersion: aws.StringValue(res.ExecutedVersion),
+		FunctionError:   aws.StringValue(res.FunctionError),
+		Payload:         res.Payload,
+	}
+	if res.LogResult != nil {
+		log, err := base64.StdEncoding.DecodeString(aws.StringValue(res.LogResult))
+		if err != nil {
+			return result, fmt.Errorf("decoding the log of function %s: %w", funcName, err)
+		}
+		result.Log = strings.TrimRight(string(log), "\n")
+	}
+	return result, nil
+}
+
+// invokeTimeout bounds ctx by the function's configured timeout and invokeHeadroom. When the timeout cannot be
+// looked up only ctx bounds the invocation
+func (p *Provisioner) invokeTimeout(ctx context.Context, funcName string) (context.Context, context.CancelFunc) {
+	lookup, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	fn, err := p.LambdaSvc.GetFunctionConfigurationWithContext(lookup, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(funcName),
+	})
+	if err != nil || fn.Timeout == nil {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(aws.Int64Value(fn.Timeout))*time.Second+invokeHeadroom)
+}
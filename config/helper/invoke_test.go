@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestInvoke(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{FunctionName: aws.String("stack-action")}
+
+	tests := []struct {
+		payload string
+		async   bool
+		status  int64
+		log     string
+		ok      bool
+	}{
+		{payload: `{"a":1}`, status: 200, log: "START RequestId: 1\nEND RequestId: 1", ok: true},
+		{payload: "fail", status: 200, log: "START RequestId: 1\nEND RequestId: 1", ok: false},
+		{payload: `{"a":1}`, async: true, status: 202, ok: true},
+	}
+	for _, test := range tests {
+		res, err := p.Invoke(ctx, "stack-action:live", []byte(test.payload), test.async)
+		if err != nil {
+			t.Fatalf("Invoke failed: %v", err)
+		}
+		if res.StatusCode != test.status || res.Log != test.log || res.OK() != test.ok {
+			t.Errorf("Invoke failed, expected status %d, log %q and ok %v, got %d, %q and %v",
+				test.status, test.log, test.ok, res.StatusCode, res.Log, res.OK())
+		}
+	}
+
+	p.Timeout = time.Minute
+	fl.functions["stack-action"].Timeout = aws.Int64(900)
+	if _, err := p.Invoke(ctx, "stack-action", nil, false); err != nil || fl.invokeDeadline < 15*time.Minute {
+		t.Errorf("Invoke failed, expected to wait for the function's 15 minute timeout, got %v, %v", fl.invokeDeadline, err)
+	}
+	if _, err := p.Invoke(ctx, "stack-action", nil, true); err != nil || fl.invokeDeadline <= 0 || fl.invokeDeadline > p.Timeout {
+		t.Errorf("Invoke failed, expected an asynchronous invocation bounded by %v, got %v, %v", p.Timeout, fl.invokeDeadline, err)
+	}
+
+	if _, err := p.Invoke(ctx, "missing", nil, false); !isNotFound(err) {
+		t.Errorf("Invoke failed, expected not found for a missing function, got %v", err)
+	}
+}
+
+func TestProxyRequest(t *testing.T) {
+	p, _, _, _ := newFakeProvisioner()
+
+	b, err := p.ProxyRequest("/stack-api", `{"a":1}`)
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatalf("ProxyRequest failed, expected JSON, got %s", b)
+	}
+	if event["httpMethod"] != "POST" || event["path"] != "/stack-api" || event["body"] != `{"a":1}` {
+		t.Errorf("ProxyRequest failed, expected a POST of the body to /stack-api, got %s", b)
+	}
+	if ctx, _ := event["requestContext"].(map[string]interface{}); ctx["accountId"] != "123456789012" {
+		t.Errorf("ProxyRequest failed, expected the account in the request context, got %v", event["requestContext"])
+	}
+}
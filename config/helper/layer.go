@@ -0,0 +1,149 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/deploy"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Layer describes a layer version to publish from the files under Source, which Lambda extracts to /opt in the
+// execution environment of each function using it. Runtimes are the runtimes the layer is compatible with,
+// empty for any
+type Layer struct {
+	Name        string
+	Description string
+	Source      string
+	Runtimes    []string
+}
+
+// PublishLayer zips l.Source and publishes it as a new version of layer l.Name, creating the layer if it does
+// not exist. Packages are staged in S3 as function packages are, see functionCode
+func (p *Provisioner) PublishLayer(ctx context.Context, l Layer) (*lambda.PublishLayerVersionOutput, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	pkg, err := deploy.ZipDir(l.Source)
+	if err != nil {
+		return nil, fmt.Errorf("packaging layer %s: %w", l.Name, err)
+	}
+	code, err := p.functionCode(ctx, "layers/"+l.Name, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &lambda.PublishLayerVersionInput{
+		LayerName:   aws.String(l.Name),
+		Description: optional(l.Description),
+		Content: &lambda.LayerVersionContentInput{
+			ZipFile:         code.ZipFile,
+			S3Bucket:        code.S3Bucket,
+			S3Key:           code.S3Key,
+			S3ObjectVersion: code.S3ObjectVersion,
+		},
+	}
+	if len(l.Runtimes) > 0 {
+		in.CompatibleRuntimes = aws.StringSlice(l.Runtimes)
+	}
+	res, err := p.LambdaSvc.PublishLayerVersionWithContext(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("publishing layer %s: %w", l.Name, err)
+	}
+	return res, nil
+}
+
+// LayerVersions returns the versions of a layer, newest first
+func (p *Provisioner) LayerVersions(ctx context.Context, name string) ([]*lambda.LayerVersionsListItem, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var versions []*lambda.LayerVersionsListItem
+	err := p.LambdaSvc.ListLayerVersionsPagesWithContext(ctx, &lambda.ListLayerVersionsInput{
+		LayerName: aws.String(name),
+	}, func(page *lambda.ListLayerVersionsOutput, lastPage bool) bool {
+		versions = append(versions, page.LayerVersions...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing the versions of layer %s: %w", name, err)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return aws.Int64Value(versions[i].Version) > aws.Int64Value(versions[j].Version)
+	})
+	return versions, nil
+}
+
+// PruneLayer deletes every version of a layer but the newest keep, and returns the versions deleted. Functions
+// already using a deleted version keep working, but it can no longer be added to a function
+func (p *Provisioner) PruneLayer(ctx context.Context, name string, keep int) ([]int64, error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("the number of versions of layer %s to keep cannot be negative, got %d", name, keep)
+	}
+	versions, err := p.LayerVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) <= keep {
+		return nil, nil
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var deleted []int64
+	for _, v := range versions[keep:] {
+		_, err := p.LambdaSvc.DeleteLayerVersionWithContext(ctx, &lambda.DeleteLayerVersionInput{
+			LayerName:     aws.String(name),
+			VersionNumber: v.Version,
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("deleting version %d of layer %s: %w", aws.Int64Value(v.Version), name, err)
+		}
+		deleted = append(deleted, aws.Int64Value(v.Version))
+	}
+	return deleted, nil
+}
+
+// resolveLayers returns the layer version ARNs of layers, which are each a layer version ARN, used as is, a
+// layer name, resolved to its latest version, or a name and version as in "name:3"
+func (p *Provisioner) resolveLayers(ctx context.Context, layers []string) ([]string, error) {
+	var arns []string
+	for _, layer := range layers {
+		if strings.HasPrefix(layer, "arn:") {
+			arns = append(arns, layer)
+			continue
+		}
+
+		name, version := layer, int64(0)
+		if i := strings.LastIndex(layer, ":"); i >= 0 {
+			n, err := strconv.ParseInt(layer[i+1:], 10, 64)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("layer %s: the version after the name must be a number from 1", layer)
+			}
+			name, version = layer[:i], n
+		}
+
+		versions, err := p.LayerVersions(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		arn := ""
+		for _, v := range versions {
+			if version == 0 || aws.Int64Value(v.Version) == version {
+				arn = aws.StringValue(v.LayerVersionArn)
+				break
+			}
+		}
+		if arn == "" {
+			return nil, fmt.Errorf("layer %s has no version to attach", layer)
+		}
+		arns = append(arns, arn)
+	}
+	return arns, nil
+}
@@ -0,0 +1,81 @@
+package helper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestLayers(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "ca.pem"), []byte("cert"), 0644)
+
+	for i := 0; i < 3; i++ {
+		res, err := p.PublishLayer(ctx, Layer{Name: "shared", Source: dir, Runtimes: []string{"go1.x"}})
+		if err != nil {
+			t.Fatalf("PublishLayer failed: %v", err)
+		}
+		if aws.Int64Value(res.Version) != int64(i+1) || len(fl.layerCode[aws.StringValue(res.LayerVersionArn)]) == 0 {
+			t.Errorf("PublishLayer failed, expected version %d with a package, got %v", i+1, res)
+		}
+	}
+	if _, err := p.PublishLayer(ctx, Layer{Name: "empty", Source: filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("PublishLayer expected an error for a missing source directory")
+	}
+
+	arn := "arn:aws:lambda:eu-west-2:123456789012:layer:shared:"
+	tests := []struct {
+		layers   []string
+		expected []string
+		fails    bool
+	}{
+		{layers: []string{"shared"}, expected: []string{arn + "3"}},
+		{layers: []string{"shared:2"}, expected: []string{arn + "2"}},
+		{layers: []string{"arn:aws:lambda:eu-west-2:999999999999:layer:other:7", "shared"}, expected: []string{"arn:aws:lambda:eu-west-2:999999999999:layer:other:7", arn + "3"}},
+		{layers: []string{"shared:9"}, fails: true},
+		{layers: []string{"shared:latest"}, fails: true},
+		{layers: []string{"missing"}, fails: true},
+	}
+	for _, tt := range tests {
+		got, err := p.resolveLayers(ctx, tt.layers)
+		if (err != nil) != tt.fails || !tt.fails && !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("resolveLayers of %v failed, expected %v (error %v), got %v, %v", tt.layers, tt.expected, tt.fails, got, err)
+		}
+	}
+
+	code, _ := writePackage(t, "main")
+	if _, err := p.CreateLambda(ctx, Lambda{
+		FunctionName: "stack-action", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/r", Code: code, Layers: []string{"shared:1"},
+	}); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	if got := fl.functions["stack-action"].Layers; len(got) != 1 || aws.StringValue(got[0].Arn) != arn+"1" {
+		t.Errorf("CreateLambda failed, expected layer %s, got %v", arn+"1", got)
+	}
+	if _, err := p.UpdateLambda(ctx, Lambda{FunctionName: "stack-action", Layers: []string{"shared"}}, false, ""); err != nil {
+		t.Fatalf("UpdateLambda failed: %v", err)
+	}
+	if got := fl.functions["stack-action"].Layers; len(got) != 1 || aws.StringValue(got[0].Arn) != arn+"3" {
+		t.Errorf("UpdateLambda failed, expected layer %s, got %v", arn+"3", got)
+	}
+
+	deleted, err := p.PruneLayer(ctx, "shared", 1)
+	if err != nil || !reflect.DeepEqual(deleted, []int64{2, 1}) {
+		t.Errorf("PruneLayer failed, expected versions 2 and 1 deleted, got %v, %v", deleted, err)
+	}
+	versions, err := p.LayerVersions(ctx, "shared")
+	if err != nil || len(versions) != 1 || aws.Int64Value(versions[0].Version) != 3 {
+		t.Errorf("LayerVersions failed, expected only version 3 left, got %v, %v", versions, err)
+	}
+}
@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// logGroup returns the log group Lambda writes the logs of a function to
+func logGroup(funcName string) string {
+	return "/aws/lambda/" + baseName(funcName)
+}
+
+// FunctionLogs passes the events a function logged since the given time to fn, in time order across all of its
+// log streams. filter is a CloudWatch Logs filter pattern, empty for every event. Without follow it returns once
+// the events so far are read, with follow it polls for new events until ctx is done, waiting for the log group
+// to appear if the function has not been invoked yet
+func (p *Provisioner) FunctionLogs(ctx context.Context, funcName string, since time.Time, filter string, follow bool, fn func(*cloudwatchlogs.FilteredLogEvent)) error {
+	group := logGroup(funcName)
+	start := aws.TimeUnixMilli(since)
+	// seen holds the IDs of the events already passed to fn at start, as the next read includes them again
+	seen := map[string]bool{}
+
+	for {
+		events, err := p.logEvents(ctx, group, start, filter)
+		if err != nil && !(follow && isNotFound(err)) {
+			return fmt.Errorf("reading log group %s: %w", group, err)
+		}
+		for _, e := range events {
+			if !seen[aws.StringValue(e.EventId)] {
+				fn(e)
+			}
+		}
+		if n := len(events); n > 0 && aws.Int64Value(events[n-1].Timestamp) > start {
+			start, seen = aws.Int64Value(events[n-1].Timestamp), map[string]bool{}
+		}
+		for _, e := range events {
+			if aws.Int64Value(e.Timestamp) == start {
+				seen[aws.StringValue(e.EventId)] = true
+			}
+		}
+
+		if !follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// logEvents reads every event in the log group from start, in milliseconds since the epoch, sorted by time
+func (p *Provisioner) logEvents(ctx context.Context, group string, start int64, filter string) ([]*cloudwatchlogs.FilteredLogEvent, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var events []*cloudwatchlogs.FilteredLogEvent
+	err := p.LogsSvc.FilterLogEventsPagesWithContext(ctx, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:  aws.String(group),
+		StartTime:     aws.Int64(start),
+		FilterPattern: optional(filter),
+	}, func(page *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		events = append(events, page.Events...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return aws.Int64Value(events[i].Timestamp) < aws.Int64Value(events[j].Timestamp)
+	})
+	return events, nil
+}
@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+func logEvent(id, stream string, at int64, msg string) *cloudwatchlogs.FilteredLogEvent {
+	return &cloudwatchlogs.FilteredLogEvent{EventId: aws.String(id), LogStreamName: aws.String(stream), Timestamp: aws.Int64(at), Message: aws.String(msg)}
+}
+
+func TestFunctionLogs(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	p, _, _, _ := newFakeProvisioner()
+	fl := p.LogsSvc.(*fakeLogs)
+	fl.groups["/aws/lambda/stack-action"] = []*cloudwatchlogs.FilteredLogEvent{
+		logEvent("1", "a", 1000, "START"),
+		logEvent("3", "a", 3000, "ERROR boom"),
+		logEvent("2", "b", 2000, "ERROR bang"),
+		logEvent("4", "b", 3000, "END"),
+	}
+	fl.later = []*cloudwatchlogs.FilteredLogEvent{logEvent("5", "a", 3000, "ERROR late"), logEvent("6", "b", 4000, "REPORT")}
+
+	tests := []struct {
+		since  int64
+		filter string
+		want   []string
+	}{
+		{since: 0, want: []string{"1", "2", "3", "4"}},
+		{since: 2000, filter: "ERROR", want: []string{"2", "3", "5"}},
+	}
+	for _, test := range tests {
+		var got []string
+		err := p.FunctionLogs(context.Background(), "stack-action:live", aws.MillisecondsTimeValue(aws.Int64(test.since)), test.filter, false,
+			func(e *cloudwatchlogs.FilteredLogEvent) { got = append(got, aws.StringValue(e.EventId)) })
+		if err != nil {
+			t.Fatalf("FunctionLogs failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FunctionLogs failed, expected %v, got %v", test.want, got)
+		}
+	}
+
+	fl.groups["/aws/lambda/stack-action"] = fl.groups["/aws/lambda/stack-action"][:4]
+	fl.later = []*cloudwatchlogs.FilteredLogEvent{logEvent("5", "a", 3000, "ERROR late"), logEvent("6", "b", 4000, "REPORT")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []string
+	err := p.FunctionLogs(ctx, "stack-action", time.Unix(0, 0), "", true, func(e *cloudwatchlogs.FilteredLogEvent) {
+		if got = append(got, aws.StringValue(e.EventId)); len(got) == 6 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("FunctionLogs failed, expected to follow until cancelled, got %v", err)
+	}
+	if want := []string{"1", "2", "3", "4", "5", "6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FunctionLogs failed, expected each event once, %v, got %v", want, got)
+	}
+
+	if err := p.FunctionLogs(context.Background(), "missing", time.Unix(0, 0), "", false, func(*cloudwatchlogs.FilteredLogEvent) {}); !isNotFound(err) {
+		t.Errorf("FunctionLogs failed, expected not found for a missing log group, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// mutatingPrefixes are the operation name prefixes of calls which change something, a dry run records these
+// instead of sending them. Anything else is a read and is sent as normal
+var mutatingPrefixes = []string{
+	"Add", "Attach", "Create", "Delete", "Detach", "Invoke", "Publish", "Put", "Remove", "Tag", "Test", "Untag", "Update",
+}
+
+// PlannedCall is a single call a dry run would have made
+type PlannedCall struct {
+	Service   string
+	Operation string
+	Params    interface{}
+}
+
+func (c PlannedCall) String() string {
+	return c.Service + " " + c.Operation + " " + awsutil.Prettify(c.Params)
+}
+
+// Plan collects the calls a dry run would have made, in the order they would have been made
+type Plan struct {
+	Calls []PlannedCall
+
+	region  string
+	account string
+	// planned holds the names and placeholder IDs of resources the plan creates, reads of these cannot be
+	// sent to AWS as the resources do not exist yet
+	planned map[string]bool
+	next    int
+	// iamSvc and lambdaSvc send as normal, they look up whether a resource the plan creates already exists
+	iamSvc    iamiface.IAMAPI
+	lambdaSvc lambdaiface.LambdaAPI
+}
+
+// NewDryRunProvisioner creates a Provisioner whose clients send reads to AWS as normal but record every call
+// which would change something in plan instead of sending it. It runs the same code as a real Provisioner so
+// the plan matches what would actually be done, placeholder outputs stand in for the resources not created. A
+// role or function which already exists fails its create as it would when applied, so the plan follows the
+// Provisioner's OnConflict
+func NewDryRunProvisioner(sess *session.Session, plan *Plan) *Provisioner {
+	plan.iamSvc, plan.lambdaSvc = iam.New(sess), lambda.New(sess)
+	dry := sess.Copy()
+	dry.Handlers.Send.Swap(corehandlers.SendHandler.Name, request.NamedHandler{
+		Name: "lambdaandfun.DryRunHandler",
+		Fn:   plan.send,
+	})
+
+	p := NewProvisioner(dry)
+	p.DryRun = true
+	plan.region, plan.account = p.Region, p.Account
+	return p
+}
+
+// SetAccount sets the account ID used in the placeholder ARNs of planned resources
+func (pl *Plan) SetAccount(account string) {
+	pl.account = account
+}
+
+// Print writes each planned call to w
+func (pl *Plan) Print(w io.Writer) {
+	if len(pl.Calls) == 0 {
+		fmt.Fprintln(w, "No changes planned")
+		return
+	}
+	for i, c := range pl.Calls {
+		fmt.Fprintf(w, "%d. %s\n", i+1, c)
+	}
+}
+
+// send replaces the SDK's send handler. Mutating calls and reads of planned resources are answered locally,
+// apart from creates of resources which already exist which fail as they would when sent
+func (pl *Plan) send(r *request.Request) {
+	mutating := isMutating(r.Operation.Name)
+	if !mutating && !pl.refersToPlanned(r.Params) {
+		corehandlers.SendHandler.Fn(r)
+		return
+	}
+	if mutating {
+		if err := pl.existing(r.Context(), r.Params); err != nil {
+			r.HTTPResponse = &http.Response{StatusCode: http.StatusConflict, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+			r.Error = err
+			return
+		}
+	}
+
+	if mutating {
+		pl.Calls = append(pl.Calls, PlannedCall{Service: r.ClientInfo.ServiceName, Operation: r.Operation.Name, Params: r.Params})
+	}
+
+	r.HTTPResponse = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+	r.Handlers.UnmarshalMeta.Clear()
+	r.Handlers.ValidateResponse.Clear()
+	r.Handlers.Unmarshal.Clear()
+	if !pl.fill(r.Params, r.Data) {
+		r.HTTPResponse.StatusCode = http.StatusNotFound
+		r.Error = awserr.New(apigateway.ErrCodeNotFoundException, "planned resource does not exist yet", nil)
+	}
+}
+
+// existing looks up the resource a create names, returning the error the create would fail with when it
+// already exists or the lookup fails, and nil for any other call
+func (pl *Plan) existing(ctx aws.Context, params interface{}) error {
+	var err error
+	switch in := params.(type) {
+	case *iam.CreateRoleInput:
+		if _, err = pl.iamSvc.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: in.RoleName}); err == nil {
+			return awserr.New(iam.ErrCodeEntityAlreadyExistsException, "Role with name "+aws.StringValue(in.RoleName)+" already exists.", nil)
+		}
+	case *lambda.CreateFunctionInput:
+		if _, err = pl.lambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{FunctionName: in.FunctionName}); err == nil {
+			return awserr.New(lambda.ErrCodeResourceConflictException, "Function already exist: "+aws.StringValue(in.FunctionName), nil)
+		}
+	}
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func isMutating(op string) bool {
+	for _, prefix := range mutatingPrefixes {
+		if strings.HasPrefix(op, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// refersToPlanned reports whether any string field of params names a resource the plan creates
+func (pl *Plan) refersToPlanned(params interface{}) bool {
+	v := reflect.Indirect(reflect.ValueOf(params))
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).CanInterface() {
+			continue
+		}
+		if s, ok := v.Field(i).Interface().(*string); ok && s != nil && pl.planned[*s] {
+			return true
+		}
+	}
+	return false
+}
+
+func (pl *Plan) plan(names ...*string) {
+	if pl.planned == nil {
+		pl.planned = map[string]bool{}
+	}
+	for _, name := range names {
+		if aws.StringValue(name) != "" {
+			pl.planned[*name] = true
+		}
+	}
+}
+
+func (pl *Plan) placeholder(kind string) *string {
+	pl.next++
+	id := aws.String(fmt.Sprintf("<planned-%s-%d>", kind, pl.next))
+	pl.plan(id)
+	return id
+}
+
+func (pl *Plan) accountID() string {
+	if pl.account == "" {
+		return "<account>"
+	}
+	return pl.account
+}
+
+func (pl *Plan) functionConfig(name *string) *lambda.FunctionConfiguration {
+	return &lambda.FunctionConfiguration{
+		FunctionName:     name,
+		FunctionArn:      aws.String("arn:aws:lambda:" + pl.region + ":" + pl.accountID() + ":function:" + aws.StringValue(name)),
+		State:            aws.String(lambda.StateActive),
+		LastUpdateStatus: aws.String(lambda.LastUpdateStatusSuccessful),
+	}
+}
+
+// fill sets placeholder values on the output of a call answered locally, for the fields later steps use. It
+// returns false when the call is a read of something which does not exist until the plan is applied
+func (pl *Plan) fill(params, data interface{}) bool {
+	switch out := data.(type) {
+	case *iam.CreateRoleOutput:
+		in := params.(*iam.CreateRoleInput)
+		pl.plan(in.RoleName)
+		out.Role = &iam.Role{
+			RoleName:                 in.RoleName,
+			Arn:                      aws.String("arn:aws:iam::" + pl.accountID() + ":role/" + aws.StringValue(in.RoleName)),
+			AssumeRolePolicyDocument: in.AssumeRolePolicyDocument,
+		}
+	case *iam.GetRoleOutput:
+		in := params.(*iam.GetRoleInput)
+		out.Role = &iam.Role{
+			RoleName: in.RoleName,
+			Arn:      aws.String("arn:aws:iam::" + pl.accountID() + ":role/" + aws.StringValue(in.RoleName)),
+		}
+	case *lambda.FunctionConfiguration:
+		var name *string
+		version := "$LATEST"
+		switch in := params.(type) {
+		case *lambda.CreateFunctionInput:
+			pl.plan(in.FunctionName)
+			name = in.FunctionName
+		case *lambda.GetFunctionConfigurationInput:
+			name = in.FunctionName
+		case *lambda.UpdateFunctionConfigurationInput:
+			name = in.FunctionName
+		case *lambda.UpdateFunctionCodeInput:
+			name = in.FunctionName
+			if aws.BoolValue(in.Publish) {
+				version = "<planned-version>"
+			}
+		case *lambda.PublishVersionInput:
+			name, version = in.FunctionName, "<planned-version>"
+		}
+		*out = *pl.functionConfig(name)
+		out.Version = aws.String(version)
+	case *lambda.GetFunctionOutput:
+		out.Configuration = pl.functionConfig(params.(*lambda.GetFunctionInput).FunctionName)
+	case *apigateway.RestApi:
+		if in, ok := params.(*apigateway.CreateRestApiInput); ok {
+			out.Id, out.Name, out.Description = pl.placeholder("rest-api"), in.Name, in.Description
+		}
+	case *apigateway.GetResourcesOutput:
+		out.Items = []*apigateway.Resource{{Id: pl.placeholder("root-resource"), Path: aws.String("/")}}
+	case *apigateway.Resource:
+		if in, ok := params.(*apigateway.CreateResourceInput); ok {
+			out.Id, out.PathPart, out.ParentId = pl.placeholder("resource"), in.PathPart, in.ParentId
+		}
+	case *apigateway.Deployment:
+		out.Id = pl.placeholder("deployment")
+	case *cloudwatchevents.PutRuleOutput:
+		in := params.(*cloudwatchevents.PutRuleInput)
+		out.RuleArn = aws.String("arn:aws:events:" + pl.region + ":" + pl.accountID() + ":rule/" + aws.StringValue(in.Name))
+	case *apigateway.Stage:
+		return isMutating(reflect.TypeOf(params).Elem().Name())
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// newPlanServer serves the lookups a dry run sends, finding role stack-role and function stack-action when
+// existing and answering not found otherwise. Every request is recorded in sent, as its method and path for
+// Lambda and its action for IAM
+func newPlanServer(t *testing.T, existing bool) (*session.Session, *[]string) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			r.ParseForm()
+			sent = append(sent, r.Form.Get("Action"))
+			if r.Form.Get("Action") == "GetRole" && existing {
+				fmt.Fprint(w, `<GetRoleResponse><GetRoleResult><Role><RoleName>stack-role</RoleName>`+
+					`<Arn>arn:aws:iam::123456789012:role/stack-role</Arn></Role></GetRoleResult></GetRoleResponse>`)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>NoSuchEntity</Code><Message>not found</Message></Error></ErrorResponse>`)
+			return
+		}
+
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/functions/stack-action/configuration") && existing {
+			fmt.Fprint(w, `{"FunctionName":"stack-action","FunctionArn":"arn:aws:lambda:eu-west-2:123456789012:function:stack-action",`+
+				`"Role":"arn:aws:iam::123456789012:role/stack-role","State":"Active","LastUpdateStatus":"Successful"}`)
+			return
+		}
+		w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"Type":"User","Message":"not found"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	return session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-2"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})), &sent
+}
+
+// onlyReads reports whether every request sent was a lookup
+func onlyReads(sent []string) bool {
+	for _, req := range sent {
+		if !strings.HasPrefix(req, "Get") && !strings.HasPrefix(req, "List") && !strings.HasPrefix(req, http.MethodGet+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+func planStack(t *testing.T) Stack {
+	code, _ := writePackage(t, "main")
+	return Stack{
+		Role:     Role{RoleName: "stack-role", Service: "lambda.amazonaws.com"},
+		Policies: []string{"service-role/AWSLambdaBasicExecutionRole"},
+		Lambda:   Lambda{FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Code: code},
+		Gateway:  Gateway{Name: "stack-api"},
+	}
+}
+
+func plannedOps(plan *Plan) []string {
+	var ops []string
+	for _, c := range plan.Calls {
+		ops = append(ops, c.Operation)
+	}
+	return ops
+}
+
+func TestDryRunPlansWithoutSending(t *testing.T) {
+	sess, sent := newPlanServer(t, false)
+	plan := &Plan{}
+	p := NewDryRunProvisioner(sess, plan)
+	plan.SetAccount("123456789012")
+
+	if err := p.CreateAllResources(context.Background(), planStack(t)); err != nil {
+		t.Fatalf("CreateAllResources in a dry run failed: %v", err)
+	}
+	if !onlyReads(*sent) {
+		t.Errorf("CreateAllResources in a dry run failed, expected only lookups to be sent, got %v", *sent)
+	}
+
+	ops := plannedOps(plan)
+	for _, want := range []string{"CreateRole", "AttachRolePolicy", "CreateFunction", "CreateRestApi", "CreateDeployment", "AddPermission"} {
+		if !contains(ops, want) {
+			t.Errorf("CreateAllResources in a dry run failed, expected %s to be planned, got %v", want, ops)
+		}
+	}
+	if p.State != nil {
+		t.Errorf("NewDryRunProvisioner failed, expected no state, got %v", p.State)
+	}
+}
+
+func TestDryRunPlansAgainstExisting(t *testing.T) {
+	sess, _ := newPlanServer(t, true)
+
+	p := NewDryRunProvisioner(sess, &Plan{})
+	if err := p.CreateAllResources(context.Background(), planStack(t)); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("CreateAllResources in a dry run against an existing role expected ErrAlreadyExists, got %v", err)
+	}
+
+	tests := []struct {
+		mode    ConflictMode
+		planned []string
+		skipped []string
+	}{
+		{mode: ConflictAdopt, planned: []string{"AttachRolePolicy", "CreateRestApi"}, skipped: []string{"CreateRole", "CreateFunction", "UpdateFunctionCode"}},
+		{mode: ConflictUpdate, planned: []string{"UpdateAssumeRolePolicy", "UpdateFunctionCode", "CreateRestApi"}, skipped: []string{"CreateRole", "CreateFunction"}},
+	}
+	for _, tt := range tests {
+		sess, sent := newPlanServer(t, true)
+		plan := &Plan{}
+		p := NewDryRunProvisioner(sess, plan)
+		p.OnConflict = tt.mode
+		if err := p.CreateAllResources(context.Background(), planStack(t)); err != nil {
+			t.Fatalf("CreateAllResources in a dry run with %s failed: %v", tt.mode, err)
+		}
+		if !onlyReads(*sent) {
+			t.Errorf("CreateAllResources in a dry run with %s failed, expected only lookups to be sent, got %v", tt.mode, *sent)
+		}
+		ops := plannedOps(plan)
+		for _, op := range tt.planned {
+			if !contains(ops, op) {
+				t.Errorf("CreateAllResources in a dry run with %s failed, expected %s to be planned, got %v", tt.mode, op, ops)
+			}
+		}
+		for _, op := range tt.skipped {
+			if contains(ops, op) {
+				t.Errorf("CreateAllResources in a dry run with %s failed, expected %s not to be planned, got %v", tt.mode, op, ops)
+			}
+		}
+	}
+}
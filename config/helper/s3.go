@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// inlinePackageLimit is the largest deployment package sent inline with the function, larger packages are
+// staged in S3 as Lambda rejects them in the request
+var inlinePackageLimit = 50 << 20
+
+// functionCode returns the code to create or update a function with. Packages over the inline limit, and every
+// package once a Bucket is set, are staged in S3 and referred to by bucket, key and version
+func (p *Provisioner) functionCode(ctx context.Context, funcName string, pkg []byte) (*lambda.FunctionCode, error) {
+	if len(pkg) <= inlinePackageLimit && p.Bucket == "" {
+		return &lambda.FunctionCode{ZipFile: pkg}, nil
+	}
+
+	bucket := p.Bucket
+	if bucket == "" {
+		if p.Account == "" {
+			return nil, fmt.Errorf("package of function %s is %d bytes and has to be staged in S3, set the account or a bucket", funcName, len(pkg))
+		}
+		bucket = "lambda-and-fun-" + p.Account + "-" + p.Region
+	}
+	if err := p.ensureBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+	return p.stagePackage(ctx, bucket, funcName, pkg)
+}
+
+// stagePackage uploads pkg to the bucket under a key made from its SHA-256, so a package which is already there
+// is not uploaded again
+func (p *Provisioner) stagePackage(ctx context.Context, bucket, funcName string, pkg []byte) (*lambda.FunctionCode, error) {
+	sum := sha256.Sum256(pkg)
+	key := funcName + "/" + hex.EncodeToString(sum[:]) + ".zip"
+
+	head, err := p.S3Svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err == nil {
+		fmt.Println("Package already staged: ", "s3://"+bucket+"/"+key)
+		return &lambda.FunctionCode{S3Bucket: aws.String(bucket), S3Key: aws.String(key), S3ObjectVersion: head.VersionId}, nil
+	}
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("looking up package s3://%s/%s: %w", bucket, key, err)
+	}
+
+	put, err := p.S3Svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(pkg),
+		ContentType: aws.String("application/zip"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("uploading package to s3://%s/%s: %w", bucket, key, err)
+	}
+	fmt.Println("Package staged: ", "s3://"+bucket+"/"+key)
+	return &lambda.FunctionCode{S3Bucket: aws.String(bucket), S3Key: aws.String(key), S3ObjectVersion: put.VersionId}, nil
+}
+
+// ensureBucket creates the staging bucket with versioning enabled when it does not exist yet
+func (p *Provisioner) ensureBucket(ctx context.Context, bucket string) error {
+	_, err := p.S3Svc.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("looking up bucket %s: %w", bucket, err)
+	}
+
+	in := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	if p.Region != "" && p.Region != "us-east-1" {
+		in.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(p.Region)}
+	}
+	_, err = p.S3Svc.CreateBucketWithContext(ctx, in)
+	var aerr awserr.Error
+	switch {
+	case err == nil:
+		fmt.Println("Bucket created: ", bucket)
+	case !errors.As(err, &aerr) || aerr.Code() != s3.ErrCodeBucketAlreadyOwnedByYou:
+		return fmt.Errorf("creating bucket %s: %w", bucket, err)
+	}
+
+	_, err = p.S3Svc.PutBucketVersioningWithContext(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	})
+	if err != nil {
+		return fmt.Errorf("enabling versioning on bucket %s: %w", bucket, err)
+	}
+	return p.wait(func() error {
+		return p.S3Svc.WaitUntilBucketExistsWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}, waiterOptions()...)
+	})
+}
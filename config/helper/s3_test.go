@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestFunctionCode(t *testing.T) {
+	defer func(limit int) { inlinePackageLimit = limit }(inlinePackageLimit)
+	inlinePackageLimit = 16
+	ctx := context.Background()
+
+	p, _, _, _ := newFakeProvisioner()
+	fs := p.S3Svc.(*fakeS3)
+
+	code, err := p.functionCode(ctx, "stack-action", []byte("small"))
+	if err != nil || string(code.ZipFile) != "small" || code.S3Bucket != nil {
+		t.Errorf("functionCode failed, expected a small package inline, got %v %v", code, err)
+	}
+
+	big := []byte(strings.Repeat("x", 32))
+	code, err = p.functionCode(ctx, "stack-action", big)
+	if err != nil {
+		t.Fatalf("functionCode failed: %v", err)
+	}
+	bucket := "lambda-and-fun-123456789012-eu-west-2"
+	if aws.StringValue(code.S3Bucket) != bucket || !strings.HasPrefix(aws.StringValue(code.S3Key), "stack-action/") || code.ZipFile != nil {
+		t.Errorf("functionCode failed, expected a large package staged in %s, got %v", bucket, code)
+	}
+	if !fs.buckets[bucket] || !fs.versioned[bucket] || aws.StringValue(code.S3ObjectVersion) != "v1" {
+		t.Errorf("functionCode failed, expected a versioned bucket and object, got %v", code)
+	}
+
+	again, err := p.functionCode(ctx, "stack-action", big)
+	if err != nil || fs.puts != 1 || aws.StringValue(again.S3Key) != aws.StringValue(code.S3Key) {
+		t.Errorf("functionCode failed, expected an unchanged package not to be uploaded again, got %d uploads %v", fs.puts, err)
+	}
+
+	p.Bucket = "named-bucket"
+	code, err = p.functionCode(ctx, "stack-action", []byte("small"))
+	if err != nil || aws.StringValue(code.S3Bucket) != "named-bucket" {
+		t.Errorf("functionCode failed, expected every package staged in the named bucket, got %v %v", code, err)
+	}
+}
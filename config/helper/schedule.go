@@ -0,0 +1,238 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// scheduleTargetID is the ID of the function target of a schedule's rule, the only target the rule has
+const scheduleTargetID = "lambda"
+
+// statementIDUnsafe matches the characters of a rule name which cannot appear in a permission's statement ID
+var statementIDUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Schedule is a CloudWatch Events rule named Name invoking FunctionName, which may be qualified with an alias,
+// on a schedule. Expression is a cron(...) or rate(...) expression, or the six fields of a cron expression
+// alone. Payload is the JSON event the function is invoked with, when empty it gets the scheduled event Events
+// sends. Name defaults to the function name followed by -schedule
+type Schedule struct {
+	Name         string
+	FunctionName string
+	Expression   string
+	Payload      string
+	Description  string
+}
+
+// RuleName returns the name of the schedule's rule
+func (s Schedule) RuleName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return baseName(s.FunctionName) + "-schedule"
+}
+
+// expression returns the schedule expression of the rule, checking a bare cron expression has its six fields
+func (s Schedule) expression() (string, error) {
+	e := strings.TrimSpace(s.Expression)
+	if strings.HasPrefix(e, "cron(") || strings.HasPrefix(e, "rate(") {
+		return e, nil
+	}
+	if n := len(strings.Fields(e)); n != 6 {
+		return "", fmt.Errorf("cron expression %q has %d fields, Events expects minutes, hours, day of month, month, day of week and year", e, n)
+	}
+	return "cron(" + e + ")", nil
+}
+
+// scheduleStatementID returns the statement ID of the permission allowing the rule to invoke its function, it
+// is unique per rule so each schedule of a function has its own permission. A rule name with characters a
+// statement ID cannot hold has a hash of the name appended, so nightly.check and nightly_check differ
+func scheduleStatementID(rule string) string {
+	safe := statementIDUnsafe.ReplaceAllString(rule, "_")
+	if safe == rule {
+		return "events-" + rule
+	}
+	h := fnv.New32a()
+	h.Write([]byte(rule))
+	return fmt.Sprintf("events-%s-%08x", safe, h.Sum32())
+}
+
+// CreateSchedule creates or replaces the rule invoking the function on the schedule, with a permission allowing
+// Events to invoke the function from that rule. The rule is recorded against the function, so deleting the
+// function deletes it too. It returns the rule's ARN
+func (p *Provisioner) CreateSchedule(ctx context.Context, s Schedule) (string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	expr, err := s.expression()
+	if err != nil {
+		return "", err
+	}
+	if s.Payload != "" && !json.Valid([]byte(s.Payload)) {
+		return "", fmt.Errorf("payload of schedule %s is not valid JSON", s.RuleName())
+	}
+	fn, err := p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(s.FunctionName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("looking up function %s: %w", s.FunctionName, err)
+	}
+
+	rule := s.RuleName()
+	res, err := p.EventsSvc.PutRuleWithContext(ctx, &cloudwatchevents.PutRuleInput{
+		Name:               aws.String(rule),
+		ScheduleExpression: aws.String(expr),
+		Description:        optional(s.Description),
+		State:              aws.String(cloudwatchevents.RuleStateEnabled),
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating rule %s: %w", rule, err)
+	}
+	fmt.Println("Rule created: ", aws.StringValue(res.RuleArn), expr)
+	p.record(func(st *state.State) {
+		f := recordedFunction(st, baseName(s.FunctionName))
+		f.Schedules = append(remove(f.Schedules, rule), rule)
+	})
+
+	id := scheduleStatementID(rule)
+	_, err = p.LambdaSvc.AddPermissionWithContext(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(s.FunctionName),
+		StatementId:  aws.String(id),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    res.RuleArn,
+	})
+	// the permission is left from an earlier schedule of the same rule, as long as it names the rule's ARN
+	if isAlreadyExists(err) {
+		source, serr := p.permissionSource(ctx, s.FunctionName, id)
+		if serr != nil {
+			return aws.StringValue(res.RuleArn), fmt.Errorf("looking up permission %s: %w", id, serr)
+		}
+		if source != aws.StringValue(res.RuleArn) {
+			return aws.StringValue(res.RuleArn), fmt.Errorf("permission %s already allows %s to invoke the function: %w", id, source, err)
+		}
+	} else if err != nil {
+		return aws.StringValue(res.RuleArn), fmt.Errorf("allowing rule %s to invoke function %s: %w", rule, s.FunctionName, err)
+	}
+	p.recordPermission(s.FunctionName, id)
+
+	targets, err := p.EventsSvc.PutTargetsWithContext(ctx, &cloudwatchevents.PutTargetsInput{
+		Rule: aws.String(rule),
+		Targets: []*cloudwatchevents.Target{
+			{Id: aws.String(scheduleTargetID), Arn: fn.FunctionArn, Input: optional(s.Payload)},
+		},
+	})
+	if err == nil && aws.Int64Value(targets.FailedEntryCount) > 0 {
+		err = fmt.Errorf("%s", aws.StringValue(targets.FailedEntries[0].ErrorMessage))
+	}
+	if err != nil {
+		return aws.StringValue(res.RuleArn), fmt.Errorf("targeting function %s from rule %s: %w", s.FunctionName, rule, err)
+	}
+	return aws.StringValue(res.RuleArn), nil
+}
+
+// DeleteSchedule removes the permissions of the functions the rule targets, its targets and then the rule
+// itself. A rule which is already gone is not an error
+func (p *Provisioner) DeleteSchedule(ctx context.Context, rule string) error {
+	if err := p.deleteSchedule(ctx, rule); err != nil {
+		return err
+	}
+	p.record(func(s *state.State) {
+		for _, f := range s.Functions {
+			f.Schedules = remove(f.Schedules, rule)
+		}
+	})
+	return nil
+}
+
+// recordedSchedules returns the names of the rules recorded in state as invoking the function
+func (p *Provisioner) recordedSchedules(funcName string) []string {
+	if p.State == nil || p.State.Functions[baseName(funcName)] == nil {
+		return nil
+	}
+	return append([]string{}, p.State.Functions[baseName(funcName)].Schedules...)
+}
+
+// deleteSchedule deletes the rule for DeleteSchedule, which then forgets it in state
+func (p *Provisioner) deleteSchedule(ctx context.Context, rule string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	// Events has no paginator for targets, so the pages are followed by hand
+	var targets []*cloudwatchevents.Target
+	in := &cloudwatchevents.ListTargetsByRuleInput{Rule: aws.String(rule)}
+	for {
+		page, err := p.EventsSvc.ListTargetsByRuleWithContext(ctx, in)
+		if isNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("listing the targets of rule %s: %w", rule, err)
+		}
+		targets = append(targets, page.Targets...)
+		if page.NextToken == nil {
+			break
+		}
+		in.NextToken = page.NextToken
+	}
+
+	id := scheduleStatementID(rule)
+	var ids []*string
+	for _, t := range targets {
+		ids = append(ids, t.Id)
+		funcName := arnFunctionName(aws.StringValue(t.Arn))
+		if funcName == "" {
+			continue
+		}
+		_, err := p.LambdaSvc.RemovePermissionWithContext(ctx, &lambda.RemovePermissionInput{
+			FunctionName: aws.String(funcName),
+			StatementId:  aws.String(id),
+		})
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("removing the permission of rule %s from function %s: %w", rule, funcName, err)
+		}
+		if name := baseName(funcName); p.State != nil && p.State.Functions[name] != nil {
+			p.record(func(s *state.State) {
+				s.Functions[name].Permissions = remove(s.Functions[name].Permissions, id)
+			})
+		}
+	}
+
+	if len(ids) > 0 {
+		res, err := p.EventsSvc.RemoveTargetsWithContext(ctx, &cloudwatchevents.RemoveTargetsInput{
+			Rule: aws.String(rule),
+			Ids:  ids,
+		})
+		if err == nil && aws.Int64Value(res.FailedEntryCount) > 0 {
+			err = fmt.Errorf("%s", aws.StringValue(res.FailedEntries[0].ErrorMessage))
+		}
+		if err != nil {
+			return fmt.Errorf("removing the targets of rule %s: %w", rule, err)
+		}
+	}
+
+	_, err := p.EventsSvc.DeleteRuleWithContext(ctx, &cloudwatchevents.DeleteRuleInput{Name: aws.String(rule)})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("deleting rule %s: %w", rule, err)
+	}
+	return nil
+}
+
+// arnFunctionName returns the function name, qualified when the ARN is, of a Lambda function ARN, or an empty
+// string when the ARN is of something else
+func arnFunctionName(a string) string {
+	parsed, err := arn.Parse(a)
+	if err != nil || parsed.Service != "lambda" || !strings.HasPrefix(parsed.Resource, "function:") {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Resource, "function:")
+}
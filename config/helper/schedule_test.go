@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestScheduleExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+		fails      bool
+	}{
+		{expression: "0 2 * * ? *", expected: "cron(0 2 * * ? *)"},
+		{expression: "cron(0 2 * * ? *)", expected: "cron(0 2 * * ? *)"},
+		{expression: " rate(1 hour) ", expected: "rate(1 hour)"},
+		{expression: "0 2 * * *", fails: true},
+		{expression: "", fails: true},
+	}
+	for _, tt := range tests {
+		got, err := Schedule{Expression: tt.expression}.expression()
+		if (err != nil) != tt.fails || got != tt.expected {
+			t.Errorf("expression of %q failed, expected %q (error %v), got %q, %v", tt.expression, tt.expected, tt.fails, got, err)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	p, _, fl, _ := newFakeProvisioner()
+	fe := p.EventsSvc.(*fakeEvents)
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action"),
+	}
+
+	nightly := Schedule{FunctionName: "stack-action", Expression: "0 2 * * ? *", Payload: `{"action":"drift"}`}
+	arn, err := p.CreateSchedule(ctx, nightly)
+	if err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+	if arn != "arn:aws:events:eu-west-2:123456789012:rule/stack-action-schedule" {
+		t.Errorf("CreateSchedule failed, expected the rule named after the function, got %s", arn)
+	}
+	targets := fe.targets["stack-action-schedule"]
+	if len(targets) != 1 || aws.StringValue(targets[0].Arn) != aws.StringValue(fl.functions["stack-action"].FunctionArn) || aws.StringValue(targets[0].Input) != nightly.Payload {
+		t.Errorf("CreateSchedule failed, expected the function targeted with the payload, got %v", targets)
+	}
+	if _, err := p.CreateSchedule(ctx, nightly); err != nil {
+		t.Errorf("CreateSchedule failed to replace an existing schedule: %v", err)
+	}
+
+	hourly := Schedule{Name: "stack-action.hourly", FunctionName: "stack-action", Expression: "rate(1 hour)"}
+	if _, err := p.CreateSchedule(ctx, hourly); err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+	expected := []string{"events-stack-action-schedule", scheduleStatementID("stack-action.hourly")}
+	if got := fl.permissions["stack-action"]; len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("CreateSchedule failed, expected permissions %v, got %v", expected, got)
+	}
+
+	for _, name := range []string{"nightly.check", "nightly_check"} {
+		if _, err := p.CreateSchedule(ctx, Schedule{Name: name, FunctionName: "stack-action", Expression: "rate(1 day)"}); err != nil {
+			t.Fatalf("CreateSchedule of %s failed: %v", name, err)
+		}
+	}
+	if a, b := scheduleStatementID("nightly.check"), scheduleStatementID("nightly_check"); a == b || !contains(fl.permissions["stack-action"], a) || !contains(fl.permissions["stack-action"], b) {
+		t.Errorf("CreateSchedule failed, expected distinct permissions %s and %s, got %v", a, b, fl.permissions["stack-action"])
+	}
+	fl.sources["stack-action/"+scheduleStatementID("nightly_check")] = "arn:aws:events:eu-west-2:123456789012:rule/other"
+	if _, err := p.CreateSchedule(ctx, Schedule{Name: "nightly_check", FunctionName: "stack-action", Expression: "rate(1 day)"}); err == nil {
+		t.Errorf("CreateSchedule expected an error for a permission allowing another rule")
+	}
+
+	for _, bad := range []Schedule{
+		{FunctionName: "stack-action", Expression: "0 2 * * ? *", Payload: "{"},
+		{FunctionName: "missing", Expression: "rate(1 day)"},
+	} {
+		if _, err := p.CreateSchedule(ctx, bad); err == nil {
+			t.Errorf("CreateSchedule expected an error for %v", bad)
+		}
+	}
+
+	if err := p.DeleteSchedule(ctx, "stack-action-schedule"); err != nil {
+		t.Fatalf("DeleteSchedule failed: %v", err)
+	}
+	if _, ok := fe.rules["stack-action-schedule"]; ok || contains(fl.permissions["stack-action"], expected[0]) {
+		t.Errorf("DeleteSchedule failed, expected the rule and permission gone, got %v and %v", fe.rules, fl.permissions["stack-action"])
+	}
+	if !contains(fl.permissions["stack-action"], expected[1]) {
+		t.Errorf("DeleteSchedule failed, expected the permission of the other schedule kept, got %v", fl.permissions["stack-action"])
+	}
+	if err := p.DeleteSchedule(ctx, "stack-action-schedule"); err != nil {
+		t.Errorf("DeleteSchedule failed for a rule already gone: %v", err)
+	}
+}
+
+func TestDeleteLambdaDeletesSchedules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, _, fl, _ := newFakeProvisioner()
+	if p.State, err = state.Load(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatal(err)
+	}
+	fe := p.EventsSvc.(*fakeEvents)
+	ctx := context.Background()
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action"),
+	}
+
+	if _, err := p.CreateSchedule(ctx, Schedule{FunctionName: "stack-action", Expression: "rate(1 day)"}); err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+	if got := p.State.Functions["stack-action"].Schedules; len(got) != 1 || got[0] != "stack-action-schedule" {
+		t.Errorf("CreateSchedule failed, expected rule stack-action-schedule recorded, got %v", got)
+	}
+
+	if _, err := p.DeleteLambda(ctx, "stack-action", false); err != nil {
+		t.Fatalf("DeleteLambda failed: %v", err)
+	}
+	if len(fe.rules) != 0 || len(fe.targets["stack-action-schedule"]) != 0 {
+		t.Errorf("DeleteLambda failed, expected the schedule's rule and target gone, got %v and %v", fe.rules, fe.targets)
+	}
+	if p.State.Functions["stack-action"] != nil {
+		t.Errorf("DeleteLambda failed, expected the function forgotten, got %v", p.State.Functions["stack-action"])
+	}
+}
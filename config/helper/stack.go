@@ -0,0 +1,247 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/deploy"
+	"github.com/aws/aws-sdk-go/aws"
+	"gopkg.in/yaml.v2"
+)
+
+// Stack is the manifest describing a role, the policies attached to it, the function which assumes the role
+// and the gateway which exposes the function. It is read from a YAML or JSON file, for example;
+//
+//	role:
+//	  name: stack-action-role
+//	  service: lambda.amazonaws.com
+//	policies:
+//	  - service-role/AWSLambdaBasicExecutionRole
+//	lambda:
+//	  name: stack-action
+//	  handler: main
+//	  runtime: go1.x
+//	  source: ./function
+//	  timeout: 300
+//	  environment:
+//	    STACK_REGION: eu-west-2
+//	  reservedConcurrency: 20
+//	gateway:
+//	  name: stack-action-api
+//	  alias: live
+type Stack struct {
+	Role     Role     `yaml:"role"`
+	Policies []string `yaml:"policies"`
+	Lambda   Lambda   `yaml:"lambda"`
+	Gateway  Gateway  `yaml:"gateway"`
+}
+
+// LoadStack reads the manifest at path, JSON manifests are accepted as JSON is a subset of YAML
+func LoadStack(path string) (Stack, error) {
+	var s Stack
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.UnmarshalStrict(b, &s); err != nil {
+		return s, fmt.Errorf("parsing stack manifest %s: %w", path, err)
+	}
+	return s, s.Validate()
+}
+
+// Validate checks the manifest has the fields needed to create each resource
+func (s Stack) Validate() error {
+	switch {
+	case s.Role.RoleName == "":
+		return errors.New("stack manifest: role.name is required")
+	case s.Role.Service == "":
+		return errors.New("stack manifest: role.service is required")
+	case s.Lambda.FunctionName == "":
+		return errors.New("stack manifest: lambda.name is required")
+	case s.Lambda.Runtime == "":
+		return errors.New("stack manifest: lambda.runtime is required")
+	case deploy.Handler(s.Lambda.Handler, s.Lambda.Runtime) == "":
+		return fmt.Errorf("stack manifest: lambda.handler is required for the %s runtime", s.Lambda.Runtime)
+	case s.Lambda.Code == "" && s.Lambda.Source == "":
+		return errors.New("stack manifest: lambda.codePath or lambda.source is required")
+	case s.Gateway.Name == "":
+		return errors.New("stack manifest: gateway.name is required")
+	case s.Lambda.ProvisionedConcurrency > 0 && s.Gateway.Alias == "":
+		return errors.New("stack manifest: lambda.provisionedConcurrency needs a gateway.alias to provision")
+	}
+	return nil
+}
+
+// CreateAllResources creates every resource in the stack in dependency order; the role, its policies, the
+// function and then the gateway. The ARN of the new role is passed to the function, and the function name
+// to the gateway, so neither needs to be given in the manifest. With gateway.alias the function's code is
+// published as its first version and the alias pointed at it, with any provisioned concurrency unless an
+// existing function was adopted as it is, before the gateway is set up to invoke the alias
+func (p *Provisioner) CreateAllResources(ctx context.Context, s Stack) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	role, err := p.CreateRole(ctx, s.Role)
+	if err != nil {
+		return fmt.Errorf("creating role %s: %w", s.Role.RoleName, err)
+	}
+	fmt.Println("Role created: ", aws.StringValue(role.Arn))
+
+	for _, policy := range s.Policies {
+		_, err := p.AttachPolicy(ctx, AttachPolicyInput{
+			Policy:   policy,
+			RoleName: s.Role.RoleName,
+			Service:  s.Role.Service,
+		})
+		if err != nil {
+			return fmt.Errorf("attaching policy %s to role %s: %w", policy, s.Role.RoleName, err)
+		}
+		fmt.Println("Attached policy to role: ", policy)
+	}
+
+	l := s.Lambda
+	l.Role = aws.StringValue(role.Arn)
+	fn, adopted, err := p.createLambda(ctx, l)
+	if err != nil {
+		return fmt.Errorf("creating function %s: %w", l.FunctionName, err)
+	}
+	fmt.Println("Function created: ", aws.StringValue(fn.FunctionArn))
+
+	g := s.Gateway
+	g.FunctionName = l.FunctionName
+	if g.Alias != "" {
+		if err := p.publishAlias(ctx, l.FunctionName, g.Alias); err != nil {
+			return err
+		}
+		if l.ProvisionedConcurrency > 0 && !adopted {
+			if err := p.SetProvisionedConcurrency(ctx, l.FunctionName, g.Alias, l.ProvisionedConcurrency); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = p.SetupGateway(ctx, g)
+	return err
+}
+
+// DeleteResult reports what happened to a single resource during DeleteAllResources, Deleted is false when
+// the resource was already gone
+type DeleteResult struct {
+	Resource string
+	Name     string
+	Deleted  bool
+}
+
+func (r DeleteResult) String() string {
+	if r.Deleted {
+		return fmt.Sprintf("%s %s: deleted", r.Resource, r.Name)
+	}
+	return fmt.Sprintf("%s %s: already gone", r.Resource, r.Name)
+}
+
+// DeleteAllResources tears the stack down in the reverse of the order CreateAllResources builds it; the
+// gateway, the schedules and triggers recorded in state, the permissions allowing the gateway to invoke the function, the
+// function, the policies attached to the role, those of the stack, the VPC access policy for a function in a
+// VPC and any other recorded in state or still attached, and finally the role. Resources which
+// no longer exist are skipped, it stops at the first other error and returns the results so far
+func (p *Provisioner) DeleteAllResources(ctx context.Context, s Stack) ([]DeleteResult, error) {
+	var results []DeleteResult
+
+	report := func(resource, name string, err error) error {
+		if err != nil && !isNotFound(err) && !errors.Is(err, ErrFunctionNotFound) {
+			return fmt.Errorf("deleting %s %s: %w", resource, name, err)
+		}
+		results = append(results, DeleteResult{Resource: resource, Name: name, Deleted: err == nil})
+		return nil
+	}
+
+	if s.Gateway.Name != "" || s.Gateway.ID != "" {
+		id, err := p.ResolveRestAPI(ctx, s.Gateway.Name, s.Gateway.ID)
+		switch {
+		case errors.Is(err, ErrRestAPINotFound):
+			results = append(results, DeleteResult{Resource: "gateway", Name: s.Gateway.Name})
+		case err != nil:
+			return results, fmt.Errorf("looking up gateway %s: %w", s.Gateway.Name, err)
+		default:
+			_, err = p.DeleteRestAPI(ctx, id)
+			if err := report("gateway", s.Gateway.Name, err); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	if s.Lambda.FunctionName != "" {
+		for _, rule := range p.recordedSchedules(s.Lambda.FunctionName) {
+			err := p.DeleteSchedule(ctx, rule)
+			if err := report("schedule", rule, err); err != nil {
+				return results, err
+			}
+		}
+		for _, uuid := range p.recordedTriggers(s.Lambda.FunctionName) {
+			_, err := p.DeleteTrigger(ctx, uuid)
+			if err := report("trigger", uuid, err); err != nil {
+				return results, err
+			}
+		}
+
+		removed, err := p.RemoveLambdaPermissions(ctx, qualified(s.Lambda.FunctionName, s.Gateway.Alias))
+		if err != nil {
+			return results, fmt.Errorf("removing permissions from function %s: %w", s.Lambda.FunctionName, err)
+		}
+		for _, id := range gatewayStatementIDs {
+			results = append(results, DeleteResult{Resource: "permission", Name: id, Deleted: contains(removed, id)})
+		}
+
+		// the security groups are left in place, so there is no need to wait for the network interfaces
+		_, err = p.deleteLambda(ctx, s.Lambda.FunctionName, false, false)
+		if err := report("function", s.Lambda.FunctionName, err); err != nil {
+			return results, err
+		}
+	}
+
+	if s.Role.RoleName != "" {
+		policies := append([]string{}, s.Policies...)
+		if s.Lambda.inVPC() {
+			policies = append(policies, vpcAccessPolicyArn)
+		}
+		// policies attached outside the stack, by triggers for one, would otherwise fail the role's deletion
+		others, err := p.attachedPolicies(ctx, s.Role.RoleName)
+		if err != nil {
+			return results, err
+		}
+		for _, policy := range others {
+			if !contains(policies, policy) && !contains(policyArns(policies, s.Role.Service), policy) {
+				policies = append(policies, policy)
+			}
+		}
+		for _, policy := range policies {
+			_, err := p.DeleteAttachedPolicy(ctx, AttachPolicyInput{
+				Policy:   policy,
+				RoleName: s.Role.RoleName,
+				Service:  s.Role.Service,
+			})
+			if err := report("policy", policy, err); err != nil {
+				return results, err
+			}
+		}
+
+		_, err = p.DeleteRole(ctx, s.Role.RoleName)
+		if err := report("role", s.Role.RoleName, err); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
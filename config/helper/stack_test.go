@@ -0,0 +1,235 @@
+package helper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func writeManifest(t *testing.T, name, body string) string {
+	dir, err := ioutil.TempDir("", "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadStack(t *testing.T) {
+	yml := writeManifest(t, "stack.yaml", `
+role:
+  name: stack-role
+  service: lambda.amazonaws.com
+policies:
+  - service-role/AWSLambdaBasicExecutionRole
+lambda:
+  name: stack-action
+  handler: main
+  runtime: go1.x
+  codePath: deployment.zip
+  memorySize: 256
+  timeout: 120
+  environment:
+    STAGE: prod
+  reservedConcurrency: 0
+gateway:
+  name: stack-api
+`)
+	json := writeManifest(t, "stack.json", `{
+  "role": {"name": "stack-role", "service": "lambda.amazonaws.com"},
+  "policies": ["service-role/AWSLambdaBasicExecutionRole"],
+  "lambda": {"name": "stack-action", "handler": "main", "runtime": "go1.x", "codePath": "deployment.zip",
+    "memorySize": 256, "timeout": 120, "environment": {"STAGE": "prod"},
+    "reservedConcurrency": 0},
+  "gateway": {"name": "stack-api"}
+}`)
+
+	for _, path := range []string{yml, json} {
+		s, err := LoadStack(path)
+		if err != nil {
+			t.Fatalf("LoadStack(%q) failed: %v", path, err)
+		}
+		if s.Role.RoleName != "stack-role" || s.Lambda.FunctionName != "stack-action" || s.Gateway.Name != "stack-api" {
+			t.Errorf("LoadStack(%q) failed, got %+v", path, s)
+		}
+		if s.Lambda.MemorySize != 256 || s.Lambda.Timeout != 120 || s.Lambda.Environment["STAGE"] != "prod" {
+			t.Errorf("LoadStack(%q) failed, expected the runtime settings, got %+v", path, s.Lambda)
+		}
+		if s.Lambda.ReservedConcurrency == nil || *s.Lambda.ReservedConcurrency != 0 {
+			t.Errorf("LoadStack(%q) failed, expected a reserved concurrency of 0, got %v", path, s.Lambda.ReservedConcurrency)
+		}
+		if len(s.Policies) != 1 || s.Policies[0] != "service-role/AWSLambdaBasicExecutionRole" {
+			t.Errorf("LoadStack(%q) failed, expected one policy, got %v", path, s.Policies)
+		}
+	}
+}
+
+func TestLoadStackRejectsIncompleteManifest(t *testing.T) {
+	path := writeManifest(t, "stack.yaml", `
+role:
+  name: stack-role
+`)
+	if _, err := LoadStack(path); err == nil {
+		t.Errorf("LoadStack(%q) expected an error for a manifest without a service", path)
+	}
+
+	path = writeManifest(t, "typo.yaml", `
+role:
+  name: stack-role
+  servcie: lambda.amazonaws.com
+`)
+	if _, err := LoadStack(path); err == nil {
+		t.Errorf("LoadStack(%q) expected an error for an unknown field", path)
+	}
+
+	path = writeManifest(t, "provisioned.yaml", `
+role: {name: stack-role, service: lambda.amazonaws.com}
+lambda: {name: stack-action, runtime: go1.x, codePath: deployment.zip, provisionedConcurrency: 2}
+gateway: {name: stack-api}
+`)
+	if _, err := LoadStack(path); err == nil {
+		t.Errorf("LoadStack(%q) expected an error for provisioned concurrency without a gateway alias", path)
+	}
+}
+
+func TestDeleteAllResources(t *testing.T) {
+	p, i, l, g := newFakeProvisioner()
+	ctx := context.Background()
+
+	s := Stack{
+		Role:     Role{RoleName: "stack-role", Service: "lambda.amazonaws.com"},
+		Policies: []string{"service-role/AWSLambdaBasicExecutionRole"},
+		Lambda:   Lambda{FunctionName: "stack-action"},
+		Gateway:  Gateway{Name: "stack-api"},
+	}
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role")}
+	i.attached["stack-role"] = []string{"arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"}
+	l.functions["stack-action"] = &lambda.FunctionConfiguration{FunctionName: aws.String("stack-action")}
+	l.permissions["stack-action"] = []string{gatewayStatementIDs[0]}
+	g.apis["a1b2c3"] = &apigateway.RestApi{Id: aws.String("a1b2c3"), Name: aws.String("stack-api")}
+
+	results, err := p.DeleteAllResources(ctx, s)
+	if err != nil {
+		t.Fatalf("DeleteAllResources failed: %v", err)
+	}
+	want := []DeleteResult{
+		{"gateway", "stack-api", true},
+		{"permission", gatewayStatementIDs[0], true},
+		{"permission", gatewayStatementIDs[1], false},
+		{"function", "stack-action", true},
+		{"policy", "service-role/AWSLambdaBasicExecutionRole", true},
+		{"role", "stack-role", true},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("DeleteAllResources failed, expected %v, got %v", want, results)
+	}
+
+	results, err = p.DeleteAllResources(ctx, s)
+	if err != nil {
+		t.Fatalf("DeleteAllResources on a deleted stack failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Deleted {
+			t.Errorf("DeleteAllResources on a deleted stack failed, expected %v to be already gone", r)
+		}
+	}
+}
+
+func TestDeleteAllResourcesSchedulesTriggersAndPolicies(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, i, l, _ := newFakeProvisioner()
+	if p.State, err = state.Load(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role")}
+	i.attached["stack-role"] = []string{"arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole", "arn:aws:iam::123456789012:policy/extra"}
+	l.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), Role: aws.String("arn:aws:iam::123456789012:role/stack-role"),
+	}
+	m, err := p.CreateTrigger(ctx, Trigger{FunctionName: "stack-action", Source: "arn:aws:sqs:eu-west-2:123456789012:stack-events"})
+	if err != nil {
+		t.Fatalf("CreateTrigger failed: %v", err)
+	}
+	l.functions["stack-action"].FunctionArn = aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action")
+	if _, err := p.CreateSchedule(ctx, Schedule{FunctionName: "stack-action", Expression: "rate(1 day)"}); err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+
+	s := Stack{
+		Role:     Role{RoleName: "stack-role", Service: "lambda.amazonaws.com"},
+		Policies: []string{"service-role/AWSLambdaBasicExecutionRole"},
+		Lambda:   Lambda{FunctionName: "stack-action"},
+	}
+	results, err := p.DeleteAllResources(ctx, s)
+	if err != nil {
+		t.Fatalf("DeleteAllResources failed: %v", err)
+	}
+	want := []DeleteResult{{"schedule", "stack-action-schedule", true}, {"trigger", aws.StringValue(m.UUID), true}}
+	if len(results) < 2 || !reflect.DeepEqual(results[:2], want) {
+		t.Errorf("DeleteAllResources failed, expected %v deleted first, got %v", want, results)
+	}
+	if fe := p.EventsSvc.(*fakeEvents); len(fe.rules) != 0 || contains(l.permissions["stack-action"], scheduleStatementID("stack-action-schedule")) {
+		t.Errorf("DeleteAllResources failed, expected the schedule's rule and permission gone, got %v and %v", fe.rules, l.permissions["stack-action"])
+	}
+	if len(l.mappings) != 0 {
+		t.Errorf("DeleteAllResources failed, expected no triggers left, got %v", l.mappings)
+	}
+	if len(i.attached["stack-role"]) != 0 || i.roles["stack-role"] != nil {
+		t.Errorf("DeleteAllResources failed, expected every policy detached and the role deleted, got %v", i.attached["stack-role"])
+	}
+	if f := p.State.Functions["stack-action"]; f != nil && len(f.Triggers) != 0 {
+		t.Errorf("DeleteAllResources failed, expected no triggers recorded, got %v", f.Triggers)
+	}
+}
+
+func TestCreateAllResourcesAdoptsFunction(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	p, i, l, _ := newFakeProvisioner()
+	p.OnConflict = ConflictAdopt
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role"), Arn: aws.String("arn:aws:iam::123456789012:role/stack-role")}
+	l.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action"),
+	}
+
+	code, _ := writePackage(t, "main")
+	s := Stack{
+		Role: Role{RoleName: "stack-role", Service: "lambda.amazonaws.com"},
+		Lambda: Lambda{
+			FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Code: code,
+			ReservedConcurrency: aws.Int64(5), ProvisionedConcurrency: 2,
+		},
+		Gateway: Gateway{Name: "stack-api", Alias: "live"},
+	}
+	if err := p.CreateAllResources(context.Background(), s); err != nil {
+		t.Fatalf("CreateAllResources failed: %v", err)
+	}
+	if len(l.reserved) != 0 || len(l.provisioned) != 0 {
+		t.Errorf("CreateAllResources failed, expected the adopted function's concurrency left alone, got %v and %v", l.reserved, l.provisioned)
+	}
+}
@@ -0,0 +1,62 @@
+package helper
+
+import (
+	"fmt"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+)
+
+// record applies update to the Provisioner's state and saves it straight away, so the identifiers of anything
+// created before a later failure are not lost. It does nothing when the Provisioner has no state or in a dry run
+func (p *Provisioner) record(update func(s *state.State)) {
+	if p.State == nil || p.DryRun {
+		return
+	}
+	update(p.State)
+	if err := p.State.Save(); err != nil {
+		fmt.Println("Unable to save state to", p.State.Path(), ":", err.Error())
+	}
+}
+
+// recordedRole returns the state of the named role, adding it to the state if it is not already there
+func recordedRole(s *state.State, name string) *state.Role {
+	if s.Roles[name] == nil {
+		s.Roles[name] = &state.Role{}
+	}
+	return s.Roles[name]
+}
+
+// recordedFunction returns the state of the named function, adding it to the state if it is not already there
+func recordedFunction(s *state.State, name string) *state.Function {
+	if s.Functions[name] == nil {
+		s.Functions[name] = &state.Function{}
+	}
+	return s.Functions[name]
+}
+
+// recordedGateway returns the state of the named gateway, adding it to the state if it is not already there
+func recordedGateway(s *state.State, name string) *state.Gateway {
+	if s.Gateways[name] == nil {
+		s.Gateways[name] = &state.Gateway{}
+	}
+	return s.Gateways[name]
+}
+
+func remove(list []string, s string) []string {
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// recordPermission adds a statement ID to the permissions recorded against a function, permissions added to
+// an alias are recorded against the function
+func (p *Provisioner) recordPermission(funcName, statementID string) {
+	p.record(func(s *state.State) {
+		f := recordedFunction(s, baseName(funcName))
+		f.Permissions = append(remove(f.Permissions, statementID), statementID)
+	})
+}
@@ -0,0 +1,298 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// sourcePolicies are the managed policies allowing a function's role to read from each service an event source
+// mapping can poll
+var sourcePolicies = map[string]string{
+	"sqs":      policyArnPrefixServiceRole + "AWSLambdaSQSQueueExecutionRole",
+	"kinesis":  policyArnPrefixServiceRole + "AWSLambdaKinesisExecutionRole",
+	"dynamodb": policyArnPrefixServiceRole + "AWSLambdaDynamoDBExecutionRole",
+}
+
+// Trigger is an event source mapping, Lambda polling the SQS queue, Kinesis stream or DynamoDB stream Source
+// and invoking FunctionName, which may be qualified with an alias, with batches of up to BatchSize records. A
+// BatchSize of 0 is Lambda's default. StartingPosition, TRIM_HORIZON or LATEST, is where reading a stream
+// starts and defaults to LATEST, queues have none. A trigger which is not Enabled is created paused
+type Trigger struct {
+	FunctionName     string
+	Source           string
+	BatchSize        int64
+	StartingPosition string
+	Enabled          bool
+}
+
+// sourceService returns the service of the trigger's source, and an error when Lambda cannot poll it
+func (t Trigger) sourceService() (string, error) {
+	a, err := arn.Parse(t.Source)
+	if err != nil {
+		return "", fmt.Errorf("trigger source %s is not an ARN: %w", t.Source, err)
+	}
+	if _, ok := sourcePolicies[a.Service]; !ok || a.Service == "dynamodb" && !strings.Contains(a.Resource, "/stream/") {
+		return "", fmt.Errorf("trigger source %s must be an SQS queue, Kinesis stream or DynamoDB stream", t.Source)
+	}
+	return a.Service, nil
+}
+
+// input returns the request creating the mapping the trigger describes
+func (t Trigger) input() (*lambda.CreateEventSourceMappingInput, error) {
+	service, err := t.sourceService()
+	if err != nil {
+		return nil, err
+	}
+	in := &lambda.CreateEventSourceMappingInput{
+		FunctionName:   aws.String(t.FunctionName),
+		EventSourceArn: aws.String(t.Source),
+		Enabled:        aws.Bool(t.Enabled),
+	}
+	if t.BatchSize > 0 {
+		in.BatchSize = aws.Int64(t.BatchSize)
+	}
+
+	switch {
+	case service == "sqs" && t.StartingPosition != "":
+		return nil, fmt.Errorf("trigger source %s is a queue, which has no starting position", t.Source)
+	case service == "sqs":
+	case t.StartingPosition == "":
+		in.StartingPosition = aws.String(lambda.EventSourcePositionLatest)
+	case t.StartingPosition == lambda.EventSourcePositionLatest || t.StartingPosition == lambda.EventSourcePositionTrimHorizon:
+		in.StartingPosition = aws.String(t.StartingPosition)
+	default:
+		return nil, fmt.Errorf("starting position must be TRIM_HORIZON or LATEST, got %s", t.StartingPosition)
+	}
+	return in, nil
+}
+
+// CreateTrigger attaches the execution policy for the trigger's source to the function's role, unless the role
+// already has it, and creates the event source mapping, waiting until Lambda has finished creating it. Lambda
+// checks the role can read from the source, so creation is retried until the policy has propagated. A policy
+// the trigger attached is recorded against the function, so DeleteTrigger only detaches what it added
+func (p *Provisioner) CreateTrigger(ctx context.Context, t Trigger) (*lambda.EventSourceMappingConfiguration, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	in, err := t.input()
+	if err != nil {
+		return nil, err
+	}
+	service, _ := t.sourceService()
+	funcName := baseName(t.FunctionName)
+	roleName, err := p.functionRoleName(ctx, funcName, "")
+	if err != nil {
+		return nil, err
+	}
+	policy := sourcePolicies[service]
+	attached, err := p.policyAttached(ctx, roleName, policy)
+	if err != nil {
+		return nil, fmt.Errorf("looking up the policies of role %s: %w", roleName, err)
+	}
+	if !attached {
+		if _, err := p.AttachPolicy(ctx, AttachPolicyInput{Policy: policy, RoleName: roleName}); err != nil {
+			return nil, fmt.Errorf("attaching %s access to role %s: %w", service, roleName, err)
+		}
+		p.record(func(s *state.State) {
+			f := recordedFunction(s, funcName)
+			f.TriggerPolicies = append(remove(f.TriggerPolicies, policy), policy)
+		})
+	}
+
+	var res *lambda.EventSourceMappingConfiguration
+	err = poll(ctx, func() (bool, error) {
+		res, err = p.LambdaSvc.CreateEventSourceMappingWithContext(ctx, in)
+		if isSourceNotReadable(err) {
+			return false, nil
+		}
+		return true, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating trigger from %s for function %s: %w", t.Source, t.FunctionName, err)
+	}
+	p.record(func(s *state.State) {
+		f := recordedFunction(s, funcName)
+		f.Triggers = append(remove(f.Triggers, aws.StringValue(res.UUID)), aws.StringValue(res.UUID))
+	})
+
+	err = p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			m, err := p.LambdaSvc.GetEventSourceMappingWithContext(ctx, &lambda.GetEventSourceMappingInput{UUID: res.UUID})
+			if err != nil {
+				return false, err
+			}
+			res = m
+			return aws.StringValue(m.State) == "Enabled" || aws.StringValue(m.State) == "Disabled", nil
+		})
+	})
+	if err != nil {
+		return res, fmt.Errorf("waiting for trigger %s to be created: %w", aws.StringValue(res.UUID), err)
+	}
+	return res, nil
+}
+
+// isSourceNotReadable reports whether err is Lambda rejecting a mapping because the function's role cannot
+// read from the source yet, as a policy just attached has not finished propagating
+func isSourceNotReadable(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == lambda.ErrCodeInvalidParameterValueException &&
+		strings.Contains(aerr.Message(), "execution role does not have permissions")
+}
+
+// Triggers returns the event source mappings invoking the function, a qualified name only returns those
+// invoking that alias or version
+func (p *Provisioner) Triggers(ctx context.Context, funcName string) ([]*lambda.EventSourceMappingConfiguration, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var mappings []*lambda.EventSourceMappingConfiguration
+	err := p.LambdaSvc.ListEventSourceMappingsPagesWithContext(ctx, &lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String(funcName),
+	}, func(page *lambda.ListEventSourceMappingsOutput, lastPage bool) bool {
+		mappings = append(mappings, page.EventSourceMappings...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing the triggers of function %s: %w", funcName, err)
+	}
+	return mappings, nil
+}
+
+// DeleteTrigger deletes the event source mapping with the given UUID and waits until it is gone, the records
+// Lambda has already read are still processed. The execution policy CreateTrigger attached for the source, if
+// it attached one, is then detached from the function's role unless another trigger of the function reads from
+// the same service
+func (p *Provisioner) DeleteTrigger(ctx context.Context, uuid string) (*lambda.EventSourceMappingConfiguration, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.LambdaSvc.DeleteEventSourceMappingWithContext(ctx, &lambda.DeleteEventSourceMappingInput{UUID: aws.String(uuid)})
+	if isNotFound(err) {
+		p.forgetTrigger(uuid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("deleting trigger %s: %w", uuid, err)
+	}
+	if funcName := baseName(arnFunctionName(aws.StringValue(res.FunctionArn))); funcName != "" {
+		p.record(func(s *state.State) {
+			if f := s.Functions[funcName]; f != nil {
+				f.Triggers = remove(f.Triggers, uuid)
+			}
+		})
+	}
+
+	err = p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			_, err := p.LambdaSvc.GetEventSourceMappingWithContext(ctx, &lambda.GetEventSourceMappingInput{UUID: aws.String(uuid)})
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		})
+	})
+	if err != nil {
+		return res, fmt.Errorf("waiting for trigger %s to be deleted: %w", uuid, err)
+	}
+	if err := p.releaseSourcePolicy(ctx, res); err != nil {
+		return res, fmt.Errorf("detaching the policy of trigger %s: %w", uuid, err)
+	}
+	return res, nil
+}
+
+// recordedTriggers returns the UUIDs of the triggers recorded in state as invoking the function
+func (p *Provisioner) recordedTriggers(funcName string) []string {
+	if p.State == nil || p.State.Functions[baseName(funcName)] == nil {
+		return nil
+	}
+	return append([]string{}, p.State.Functions[baseName(funcName)].Triggers...)
+}
+
+// forgetTrigger removes a trigger which no longer exists from the state of whichever function it was recorded on
+func (p *Provisioner) forgetTrigger(uuid string) {
+	p.record(func(s *state.State) {
+		for _, f := range s.Functions {
+			f.Triggers = remove(f.Triggers, uuid)
+		}
+	})
+}
+
+// releaseSourcePolicy detaches the execution policy for the service of a deleted mapping's source from the
+// role of its function, when a trigger of the function attached it and no other mapping of the function,
+// under any alias, still reads from that service
+func (p *Provisioner) releaseSourcePolicy(ctx context.Context, m *lambda.EventSourceMappingConfiguration) error {
+	service := sourceService(m)
+	funcName := arnFunctionName(aws.StringValue(m.FunctionArn))
+	policy, ok := sourcePolicies[service]
+	if !ok || funcName == "" {
+		return nil
+	}
+	base := baseName(funcName)
+	if p.State == nil || p.State.Functions[base] == nil || !contains(p.State.Functions[base].TriggerPolicies, policy) {
+		return nil
+	}
+
+	// listing by name only returns the mappings of that exact qualifier, those of other aliases are in state
+	names := []string{base}
+	if funcName != base {
+		names = append(names, funcName)
+	}
+	var others []*lambda.EventSourceMappingConfiguration
+	for _, name := range names {
+		mappings, err := p.Triggers(ctx, name)
+		if err != nil {
+			return err
+		}
+		others = append(others, mappings...)
+	}
+	for _, uuid := range p.State.Functions[base].Triggers {
+		other, err := p.LambdaSvc.GetEventSourceMappingWithContext(ctx, &lambda.GetEventSourceMappingInput{UUID: aws.String(uuid)})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("looking up trigger %s: %w", uuid, err)
+		}
+		others = append(others, other)
+	}
+	for _, other := range others {
+		if aws.StringValue(other.UUID) != aws.StringValue(m.UUID) && aws.StringValue(other.State) != "Deleting" &&
+			sourceService(other) == service {
+			return nil
+		}
+	}
+
+	roleName, err := p.functionRoleName(ctx, base, "")
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = p.DeleteAttachedPolicy(ctx, AttachPolicyInput{Policy: policy, RoleName: roleName})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	p.record(func(s *state.State) {
+		if f := s.Functions[base]; f != nil {
+			f.TriggerPolicies = remove(f.TriggerPolicies, policy)
+		}
+	})
+	return nil
+}
+
+// sourceService returns the service of the source a mapping reads from, empty when it is not an ARN
+func sourceService(m *lambda.EventSourceMappingConfiguration) string {
+	a, err := arn.Parse(aws.StringValue(m.EventSourceArn))
+	if err != nil {
+		return ""
+	}
+	return a.Service
+}
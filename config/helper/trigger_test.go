@@ -0,0 +1,152 @@
+package helper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VariableExp0rt/lambda-and-fun/config/state"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestTriggerInput(t *testing.T) {
+	queue := "arn:aws:sqs:eu-west-2:123456789012:stack-events"
+	stream := "arn:aws:kinesis:eu-west-2:123456789012:stream/stack-events"
+	table := "arn:aws:dynamodb:eu-west-2:123456789012:table/stacks/stream/2020-08-01T00:00:00.000"
+	tests := []struct {
+		trigger  Trigger
+		position string
+		fails    bool
+	}{
+		{trigger: Trigger{Source: queue}},
+		{trigger: Trigger{Source: queue, StartingPosition: "LATEST"}, fails: true},
+		{trigger: Trigger{Source: stream}, position: "LATEST"},
+		{trigger: Trigger{Source: table, StartingPosition: "TRIM_HORIZON"}, position: "TRIM_HORIZON"},
+		{trigger: Trigger{Source: stream, StartingPosition: "EARLIEST"}, fails: true},
+		{trigger: Trigger{Source: "arn:aws:dynamodb:eu-west-2:123456789012:table/stacks"}, fails: true},
+		{trigger: Trigger{Source: "arn:aws:sns:eu-west-2:123456789012:stack-events"}, fails: true},
+		{trigger: Trigger{Source: "stack-events"}, fails: true},
+	}
+	for _, tt := range tests {
+		in, err := tt.trigger.input()
+		if (err != nil) != tt.fails || !tt.fails && aws.StringValue(in.StartingPosition) != tt.position {
+			t.Errorf("input of %s failed, expected starting position %q (error %v), got %v, %v", tt.trigger.Source, tt.position, tt.fails, in, err)
+		}
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, i, fl, _ := newFakeProvisioner()
+	if p.State, err = state.Load(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role")}
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), Role: aws.String("arn:aws:iam::123456789012:role/stack-role"),
+	}
+	fl.unreadable = 2
+
+	trigger := Trigger{FunctionName: "stack-action", Source: "arn:aws:sqs:eu-west-2:123456789012:stack-events", BatchSize: 5, Enabled: true}
+	m, err := p.CreateTrigger(ctx, trigger)
+	if err != nil {
+		t.Fatalf("CreateTrigger failed: %v", err)
+	}
+	if aws.StringValue(m.State) != "Enabled" || aws.Int64Value(m.BatchSize) != 5 {
+		t.Errorf("CreateTrigger failed, expected an enabled mapping of batches of 5, got %v", m)
+	}
+	if policy := sourcePolicies["sqs"]; !contains(i.attached["stack-role"], policy) {
+		t.Errorf("CreateTrigger failed, expected %s attached to the role, got %v", policy, i.attached["stack-role"])
+	}
+	if got := p.State.Functions["stack-action"].Triggers; len(got) != 1 || got[0] != aws.StringValue(m.UUID) {
+		t.Errorf("CreateTrigger failed, expected trigger %s recorded, got %v", aws.StringValue(m.UUID), got)
+	}
+	if _, err := p.CreateTrigger(ctx, trigger); err == nil {
+		t.Errorf("CreateTrigger expected an error for a second mapping of the same source")
+	}
+
+	trigger.Enabled = false
+	trigger.Source = "arn:aws:kinesis:eu-west-2:123456789012:stream/stack-events"
+	stream, err := p.CreateTrigger(ctx, trigger)
+	if err != nil || aws.StringValue(stream.State) != "Disabled" {
+		t.Fatalf("CreateTrigger failed, expected a disabled mapping, got %v, %v", stream, err)
+	}
+
+	mappings, err := p.Triggers(ctx, "stack-action")
+	if err != nil || len(mappings) != 2 {
+		t.Errorf("Triggers failed, expected 2 mappings, got %v, %v", mappings, err)
+	}
+
+	if _, err := p.DeleteTrigger(ctx, aws.StringValue(m.UUID)); err != nil {
+		t.Fatalf("DeleteTrigger failed: %v", err)
+	}
+	if _, ok := fl.mappings[aws.StringValue(m.UUID)]; ok {
+		t.Errorf("DeleteTrigger failed, expected to wait for mapping %s to be gone", aws.StringValue(m.UUID))
+	}
+	if got := p.State.Functions["stack-action"].Triggers; len(got) != 1 {
+		t.Errorf("DeleteTrigger failed, expected one trigger left recorded, got %v", got)
+	}
+	if policy := sourcePolicies["sqs"]; contains(i.attached["stack-role"], policy) {
+		t.Errorf("DeleteTrigger failed, expected %s detached from the role, got %v", policy, i.attached["stack-role"])
+	}
+	if policy := sourcePolicies["kinesis"]; !contains(i.attached["stack-role"], policy) {
+		t.Errorf("DeleteTrigger failed, expected %s left for the other trigger, got %v", policy, i.attached["stack-role"])
+	}
+	if _, err := p.DeleteTrigger(ctx, "missing"); err == nil {
+		t.Errorf("DeleteTrigger expected an error for a missing mapping")
+	}
+}
+
+func TestDeleteTriggerKeepsPolicyItDidNotAttach(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, i, fl, _ := newFakeProvisioner()
+	if p.State, err = state.Load(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role")}
+	fl.functions["stack-action"] = &lambda.FunctionConfiguration{
+		FunctionName: aws.String("stack-action"), Role: aws.String("arn:aws:iam::123456789012:role/stack-role"),
+	}
+	policy := sourcePolicies["sqs"]
+	if _, err := p.AttachPolicy(ctx, AttachPolicyInput{Policy: policy, RoleName: "stack-role"}); err != nil {
+		t.Fatalf("AttachPolicy failed: %v", err)
+	}
+
+	m, err := p.CreateTrigger(ctx, Trigger{FunctionName: "stack-action", Source: "arn:aws:sqs:eu-west-2:123456789012:stack-events"})
+	if err != nil {
+		t.Fatalf("CreateTrigger failed: %v", err)
+	}
+	if got := p.State.Functions["stack-action"].TriggerPolicies; len(got) != 0 {
+		t.Errorf("CreateTrigger failed, expected no policy recorded as attached by the trigger, got %v", got)
+	}
+	if _, err := p.DeleteTrigger(ctx, aws.StringValue(m.UUID)); err != nil {
+		t.Fatalf("DeleteTrigger failed: %v", err)
+	}
+	if !contains(i.attached["stack-role"], policy) || !contains(p.State.Roles["stack-role"].Policies, policy) {
+		t.Errorf("DeleteTrigger failed, expected %s kept attached and recorded, got %v and %v",
+			policy, i.attached["stack-role"], p.State.Roles["stack-role"].Policies)
+	}
+}
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// undoStack collects the steps which reverse a multi-step pipeline, they are run in reverse order when a
+// later step of the pipeline fails
+type undoStack []undoStep
+
+type undoStep struct {
+	desc string
+	fn   func(ctx context.Context) error
+}
+
+// push adds a step to run if the pipeline fails after this point
+func (u *undoStack) push(desc string, fn func(ctx context.Context) error) {
+	*u = append(*u, undoStep{desc: desc, fn: fn})
+}
+
+// run undoes every step, most recent first, and returns cause annotated with any step which could not be
+// undone. The steps run under a fresh timeout as the pipeline's own context may be the reason it failed
+func (u undoStack) run(p *Provisioner, cause error) error {
+	ctx, cancel := p.withTimeout(context.Background())
+	defer cancel()
+
+	var failed []string
+	for i := len(u) - 1; i >= 0; i-- {
+		fmt.Println("Rolling back: ", u[i].desc)
+		if err := u[i].fn(ctx); err != nil && !isNotFound(err) {
+			failed = append(failed, u[i].desc+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w (rollback incomplete, %s)", cause, strings.Join(failed, "; "))
+	}
+	return fmt.Errorf("%w (rolled back)", cause)
+}
@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestConfigureAPIEndpoint(t *testing.T) {
+	p, _, l, g := newFakeProvisioner()
+	l.functions["stack-action"] = &lambda.FunctionConfiguration{FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action")}
+
+	api, err := p.SetupGateway(context.Background(), Gateway{Name: "stack-api", FunctionName: "stack-action"})
+	if err != nil {
+		t.Fatalf("SetupGateway failed: %v", err)
+	}
+	for _, key := range []string{"method:", "integration:", "methodResponse:", "integrationResponse:", "stage:prod"} {
+		if !hasLivePrefix(g.live, key) {
+			t.Errorf("SetupGateway failed, expected %s to be configured, got %v", key, g.live)
+		}
+	}
+	if len(l.permissions["stack-action"]) != len(gatewayStatementIDs) {
+		t.Errorf("SetupGateway failed, expected permissions %v, got %v", gatewayStatementIDs, l.permissions["stack-action"])
+	}
+	if g.apis[aws.StringValue(api.Id)] == nil {
+		t.Errorf("SetupGateway failed, expected REST API %s to exist", aws.StringValue(api.Id))
+	}
+}
+
+func TestConfigureAPIEndpointRollsBack(t *testing.T) {
+	steps := []string{"PutMethod", "PutIntegration", "PutMethodResponse", "PutIntegrationResponse", "CreateDeployment", "AddPermission"}
+	for _, step := range steps {
+		p, _, l, g := newFakeProvisioner()
+		l.functions["stack-action"] = &lambda.FunctionConfiguration{FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action")}
+		if step == "AddPermission" {
+			l.failPermission, l.failAfter = errors.New("AddPermission failed"), 1
+		} else {
+			g.failOn = step
+		}
+
+		_, err := p.SetupGateway(context.Background(), Gateway{Name: "stack-api", FunctionName: "stack-action"})
+		if err == nil || !strings.Contains(err.Error(), step+" failed") || !strings.Contains(err.Error(), "rolled back") {
+			t.Errorf("SetupGateway failing at %s, expected a rolled back error, got %v", step, err)
+		}
+		if len(g.live) != 0 {
+			t.Errorf("SetupGateway failing at %s, expected everything to be undone, got %v", step, g.live)
+		}
+		if len(g.apis) != 0 {
+			t.Errorf("SetupGateway failing at %s, expected the REST API to be deleted, got %v", step, g.apis)
+		}
+		if len(l.permissions["stack-action"]) != 0 {
+			t.Errorf("SetupGateway failing at %s, expected no permissions left, got %v", step, l.permissions["stack-action"])
+		}
+	}
+}
+
+func TestConfigureAPIEndpointKeepsOtherGatewayPermissions(t *testing.T) {
+	p, _, l, _ := newFakeProvisioner()
+	l.functions["stack-action"] = &lambda.FunctionConfiguration{FunctionArn: aws.String("arn:aws:lambda:eu-west-2:123456789012:function:stack-action")}
+	ctx := context.Background()
+
+	if _, err := p.SetupGateway(ctx, Gateway{Name: "stack-api", FunctionName: "stack-action"}); err != nil {
+		t.Fatalf("SetupGateway failed: %v", err)
+	}
+	sources := map[string]string{}
+	for _, id := range gatewayStatementIDs {
+		sources[id] = l.sources["stack-action/"+id]
+	}
+
+	_, err := p.SetupGateway(ctx, Gateway{Name: "other-api", FunctionName: "stack-action"})
+	if err == nil || !strings.Contains(err.Error(), "already allows") {
+		t.Errorf("SetupGateway of a second gateway expected a conflicting permission error, got %v", err)
+	}
+	if len(l.permissions["stack-action"]) != len(gatewayStatementIDs) {
+		t.Fatalf("SetupGateway of a second gateway failed, expected permissions %v to survive, got %v", gatewayStatementIDs, l.permissions["stack-action"])
+	}
+	for _, id := range gatewayStatementIDs {
+		if got := l.sources["stack-action/"+id]; got != sources[id] {
+			t.Errorf("SetupGateway of a second gateway failed, expected permission %s to allow %s, got %s", id, sources[id], got)
+		}
+	}
+
+	added, err := p.AddLambdaPermissions(ctx, "stack-action", aws.String(sources[gatewayStatementIDs[0]]))
+	if err != nil || len(added) != 0 {
+		t.Errorf("AddLambdaPermissions with the same source expected the permissions reused, got %v, %v", added, err)
+	}
+}
+
+func hasLivePrefix(live map[string]bool, prefix string) bool {
+	for key := range live {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
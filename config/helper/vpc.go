@@ -0,0 +1,109 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// vpcAccessPolicyArn is the managed policy allowing Lambda to create and delete the network interfaces a
+// function attached to a VPC uses
+const vpcAccessPolicyArn = policyArnPrefixServiceRole + "AWSLambdaVPCAccessExecutionRole"
+
+// eniDescriptionPrefix starts the description of each network interface Lambda creates for a function, which
+// is followed by the function name, a dash and a UUID
+const eniDescriptionPrefix = "AWS Lambda VPC ENI-"
+
+// eniSuffix matches the dash and UUID following the function name in a network interface's description
+var eniSuffix = regexp.MustCompile(`^-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// inVPC reports whether l is attached to a VPC
+func (l Lambda) inVPC() bool {
+	return len(l.SubnetIDs) > 0 || len(l.SecurityGroupIDs) > 0
+}
+
+// vpcConfig returns the VPC configuration of l, or nil when it is not attached to a VPC
+func (l Lambda) vpcConfig() *lambda.VpcConfig {
+	if !l.inVPC() {
+		return nil
+	}
+	return &lambda.VpcConfig{SubnetIds: aws.StringSlice(l.SubnetIDs), SecurityGroupIds: aws.StringSlice(l.SecurityGroupIDs)}
+}
+
+// attachVPCAccess attaches the VPC access execution policy to the role of the function l describes, which
+// Lambda needs to manage the network interfaces of a function in a VPC
+func (p *Provisioner) attachVPCAccess(ctx context.Context, l Lambda) error {
+	if len(l.SubnetIDs) == 0 || len(l.SecurityGroupIDs) == 0 {
+		return fmt.Errorf("function %s needs both subnets and security groups to attach to a VPC", l.FunctionName)
+	}
+
+	roleName, err := p.functionRoleName(ctx, l.FunctionName, l.Role)
+	if err != nil {
+		return err
+	}
+	if _, err := p.AttachPolicy(ctx, AttachPolicyInput{Policy: vpcAccessPolicyArn, RoleName: roleName}); err != nil {
+		return fmt.Errorf("attaching VPC access to role %s: %w", roleName, err)
+	}
+	return nil
+}
+
+// FunctionVPC returns the VPC configuration of the named function, nil when it is not attached to a VPC
+func (p *Provisioner) FunctionVPC(ctx context.Context, funcName string) (*lambda.VpcConfigResponse, error) {
+	fn, err := p.LambdaSvc.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(funcName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fn.VpcConfig == nil || len(fn.VpcConfig.SubnetIds) == 0 {
+		return nil, nil
+	}
+	return fn.VpcConfig, nil
+}
+
+// waitForENIsReleased polls until the network interfaces Lambda created in the VPC for the function are gone.
+// Lambda releases them some minutes after the function is deleted, and until then the security groups and
+// subnets they sit in cannot be deleted. The description filter also matches the interfaces of functions whose
+// names start with funcName and a dash, so those are told apart by what follows the name
+func (p *Provisioner) waitForENIsReleased(ctx context.Context, funcName string, vpc *lambda.VpcConfigResponse) error {
+	return p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			res, err := p.EC2Svc.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+				Filters: []*ec2.Filter{
+					{Name: aws.String("description"), Values: aws.StringSlice([]string{eniDescriptionPrefix + funcName + "-*"})},
+					{Name: aws.String("subnet-id"), Values: vpc.SubnetIds},
+				},
+			})
+			if err != nil {
+				return false, err
+			}
+			for _, eni := range res.NetworkInterfaces {
+				if eniSuffix.MatchString(strings.TrimPrefix(aws.StringValue(eni.Description), eniDescriptionPrefix+funcName)) {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+	})
+}
+
+// DeleteSecurityGroups deletes the security groups with the given IDs, use it once the functions in them are
+// deleted as DeleteLambda waits for their network interfaces to be released
+func (p *Provisioner) DeleteSecurityGroups(ctx context.Context, ids []string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	for _, id := range ids {
+		_, err := p.EC2Svc.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{GroupId: aws.String(id)})
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("deleting security group %s: %w", id, err)
+		}
+		fmt.Println("Security group deleted: ", id)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+func TestVPCLambda(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	p, i, fl, _ := newFakeProvisioner()
+	fe := p.EC2Svc.(*fakeEC2)
+	ctx := context.Background()
+	code, _ := writePackage(t, "main")
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role")}
+
+	l := Lambda{
+		FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/stack-role", Code: code,
+		SubnetIDs: []string{"subnet-1"}, SecurityGroupIDs: []string{"sg-1"},
+	}
+	fl.noENIAccess = 2
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	if !contains(i.attached["stack-role"], vpcAccessPolicyArn) {
+		t.Errorf("CreateLambda failed, expected %s attached to the role, got %v", vpcAccessPolicyArn, i.attached["stack-role"])
+	}
+	if fl.noENIAccess != 0 {
+		t.Errorf("CreateLambda failed, expected to retry until the role could create network interfaces, %d failures left", fl.noENIAccess)
+	}
+	if vpc := fl.functions["stack-action"].VpcConfig; vpc == nil || aws.StringValue(vpc.SubnetIds[0]) != "subnet-1" {
+		t.Errorf("CreateLambda failed, expected the function in subnet-1, got %v", vpc)
+	}
+
+	fe.enis["stack-action"] = 3
+	fe.enis["stack-action-worker"] = 100
+	if _, err := p.DeleteLambda(ctx, "stack-action", true); err != nil {
+		t.Fatalf("DeleteLambda failed: %v", err)
+	}
+	if fe.enis["stack-action"] != 0 {
+		t.Errorf("DeleteLambda failed, expected to wait for the network interfaces to be released, %d polls left", fe.enis["stack-action"])
+	}
+	if fe.enis["stack-action-worker"] < 90 {
+		t.Errorf("DeleteLambda failed, expected not to wait for the network interfaces of stack-action-worker, %d polls left", fe.enis["stack-action-worker"])
+	}
+	if len(fe.deleted) != 1 || fe.deleted[0] != "sg-1" {
+		t.Errorf("DeleteLambda failed, expected security group sg-1 deleted, got %v", fe.deleted)
+	}
+	if _, err := p.DeleteLambda(ctx, "stack-action", true); !errors.Is(err, ErrFunctionNotFound) {
+		t.Errorf("DeleteLambda of a deleted function expected ErrFunctionNotFound, got %v", err)
+	}
+
+	l.SecurityGroupIDs = nil
+	if _, err := p.CreateLambda(ctx, l); err == nil {
+		t.Errorf("CreateLambda expected an error for subnets without security groups")
+	}
+}
+
+func TestDeleteLambdaENITimeout(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	p, i, _, _ := newFakeProvisioner()
+	fe := p.EC2Svc.(*fakeEC2)
+	ctx := context.Background()
+	code, _ := writePackage(t, "main")
+	i.roles["stack-role"] = &iam.Role{RoleName: aws.String("stack-role")}
+
+	l := Lambda{
+		FunctionName: "stack-action", Handler: "main", Runtime: "go1.x", Role: "arn:aws:iam::123456789012:role/stack-role", Code: code,
+		SubnetIDs: []string{"subnet-1"}, SecurityGroupIDs: []string{"sg-1"},
+	}
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	p.Timeout = 30 * time.Millisecond
+	fe.enis["stack-action"] = 100
+	if _, err := p.DeleteLambda(ctx, "stack-action", false); err != nil {
+		t.Fatalf("DeleteLambda failed, expected the network interface wait bounded by ENITimeout rather than Timeout: %v", err)
+	}
+	if fe.enis["stack-action"] != 0 {
+		t.Errorf("DeleteLambda failed, expected to wait for the network interfaces to be released, %d polls left", fe.enis["stack-action"])
+	}
+
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	p.ENITimeout = 10 * time.Millisecond
+	fe.enis["stack-action"] = 1 << 20
+	if _, err := p.DeleteLambda(ctx, "stack-action", true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DeleteLambda expected to give up on the network interfaces after ENITimeout, got %v", err)
+	}
+	if len(fe.deleted) != 0 {
+		t.Errorf("DeleteLambda failed, expected the security groups kept while their network interfaces are in use, got %v", fe.deleted)
+	}
+
+	if _, err := p.CreateLambda(ctx, l); err != nil {
+		t.Fatalf("CreateLambda failed: %v", err)
+	}
+	fe.enis["stack-action"] = 5
+	if _, err := p.deleteLambda(ctx, "stack-action", false, false); err != nil {
+		t.Fatalf("deleteLambda failed: %v", err)
+	}
+	if fe.enis["stack-action"] != 5 {
+		t.Errorf("deleteLambda failed, expected not to wait for the network interfaces, %d polls left", fe.enis["stack-action"])
+	}
+}
@@ -0,0 +1,146 @@
+package helper
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// DefaultTimeout is how long each create or delete operation waits for its resource to reach the end state
+const DefaultTimeout = 2 * time.Minute
+
+// DefaultENITimeout is how long DeleteLambda waits for Lambda to release the network interfaces of a VPC
+// function, which commonly takes far longer than the other operations
+const DefaultENITimeout = 45 * time.Minute
+
+// pollInterval is how long poll waits between checks, and the delay used for the SDK waiters
+var pollInterval = 2 * time.Second
+
+// withTimeout bounds a single operation by the Provisioner's Timeout, a zero Timeout only inherits the
+// deadline of ctx
+func (p *Provisioner) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.Timeout)
+}
+
+// withENITimeout bounds the wait for the network interfaces of a deleted function by the Provisioner's
+// ENITimeout, a zero ENITimeout only inherits the deadline of ctx
+func (p *Provisioner) withENITimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.ENITimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.ENITimeout)
+}
+
+// waiterOptions makes the SDK waiters give up only when ctx is done rather than after a fixed number of attempts
+func waiterOptions() []request.WaiterOption {
+	return []request.WaiterOption{
+		request.WithWaiterMaxAttempts(1 << 16),
+		request.WithWaiterDelay(request.ConstantWaiterDelay(pollInterval)),
+	}
+}
+
+// poll calls done until it reports true or returns an error, or ctx is done
+func poll(ctx context.Context, done func() (bool, error)) error {
+	for {
+		ok, err := done()
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// wait runs a wait for a resource to reach its end state, unless this is a dry run where nothing was changed
+// and there is nothing to wait for
+func (p *Provisioner) wait(fn func() error) error {
+	if p.DryRun {
+		return nil
+	}
+	return fn()
+}
+
+// gone adapts a lookup for poll, the resource is gone once the lookup returns a not found error
+func gone(err error) (bool, error) {
+	if isNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+// policyAttached reports whether the policy ARN is attached to the role
+func (p *Provisioner) policyAttached(ctx context.Context, roleName, arn string) (bool, error) {
+	var found bool
+	err := p.IAMSvc.ListAttachedRolePoliciesPagesWithContext(ctx, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	}, func(page *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+		for _, policy := range page.AttachedPolicies {
+			if aws.StringValue(policy.PolicyArn) == arn {
+				found = true
+				return false
+			}
+		}
+		return true
+	})
+	return found, err
+}
+
+// waitForRoleDeleted polls until the role can no longer be found
+func (p *Provisioner) waitForRoleDeleted(ctx context.Context, roleName string) error {
+	return p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			_, err := p.IAMSvc.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+			return gone(err)
+		})
+	})
+}
+
+// waitForFunctionDeleted polls until the function can no longer be found
+func (p *Provisioner) waitForFunctionDeleted(ctx context.Context, funcName string) error {
+	return p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			_, err := p.LambdaSvc.GetFunctionWithContext(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(funcName)})
+			return gone(err)
+		})
+	})
+}
+
+// waitForRestAPIDeleted polls until the REST API can no longer be found
+func (p *Provisioner) waitForRestAPIDeleted(ctx context.Context, apiID string) error {
+	return p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			_, err := p.APIGatewaySvc.GetRestApiWithContext(ctx, &apigateway.GetRestApiInput{RestApiId: aws.String(apiID)})
+			return gone(err)
+		})
+	})
+}
+
+// waitForDeployment polls until the stage points at the given deployment
+func (p *Provisioner) waitForDeployment(ctx context.Context, apiID, stage, deploymentID string) error {
+	return p.wait(func() error {
+		return poll(ctx, func() (bool, error) {
+			res, err := p.APIGatewaySvc.GetStageWithContext(ctx, &apigateway.GetStageInput{
+				RestApiId: aws.String(apiID),
+				StageName: aws.String(stage),
+			})
+			if isNotFound(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			return aws.StringValue(res.DeploymentId) == deploymentID, nil
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollTimesOut(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	p := &Provisioner{Timeout: 20 * time.Millisecond}
+	ctx, cancel := p.withTimeout(context.Background())
+	defer cancel()
+
+	var calls int
+	err := poll(ctx, func() (bool, error) { calls++; return false, nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("poll failed, expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if calls < 2 {
+		t.Errorf("poll failed, expected the check to be retried, got %d calls", calls)
+	}
+}
+
+func TestPollStopsOnError(t *testing.T) {
+	want := errors.New("boom")
+	err := poll(context.Background(), func() (bool, error) { return false, want })
+	if err != want {
+		t.Errorf("poll failed, expected %v, got %v", want, err)
+	}
+}
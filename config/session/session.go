@@ -2,6 +2,7 @@ package session
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 )
 
@@ -18,3 +19,15 @@ func NewSession(region string) *session.Session {
 
 	return sess
 }
+
+// UseS3Endpoint sends the S3 requests of clients created from sess to endpoint, with the path style addressing
+// local S3-compatible stand-ins such as MinIO expect. The other services keep their usual endpoints
+func UseS3Endpoint(sess *session.Session, endpoint string) {
+	sess.Config.S3ForcePathStyle = aws.Bool(true)
+	sess.Config.EndpointResolver = endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if service == endpoints.S3ServiceID {
+			return endpoints.ResolvedEndpoint{URL: endpoint, SigningRegion: region}, nil
+		}
+		return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+	})
+}
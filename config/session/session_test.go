@@ -2,9 +2,15 @@ package session
 
 import (
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 func TestNewSession(t *testing.T) {
@@ -17,3 +23,28 @@ func TestNewSession(t *testing.T) {
 		log.Printf("NewSession(\"eu-west-2\") succeeded, expected %v, got %v", "eu-west-2", r)
 	}
 }
+
+func TestUseS3Endpoint(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method + " " + r.URL.Path
+	}))
+	defer srv.Close()
+
+	ns := NewSession("eu-west-2")
+	ns.Config.Credentials = credentials.NewStaticCredentials("id", "secret", "")
+	UseS3Endpoint(ns, srv.URL)
+
+	_, err := s3.New(ns).PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("packages"),
+		Key:    aws.String("stack-action/abc.zip"),
+		Body:   strings.NewReader("zip"),
+	})
+	if err != nil || got != "PUT /packages/stack-action/abc.zip" {
+		t.Errorf("UseS3Endpoint failed, expected a path style PUT to the stand-in, got %q %v", got, err)
+	}
+
+	if u := lambda.New(ns).Endpoint; u != "https://lambda.eu-west-2.amazonaws.com" {
+		t.Errorf("UseS3Endpoint failed, expected Lambda to keep its endpoint, got %v", u)
+	}
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultPath is where the state file is kept, relative to the working directory
+const DefaultPath = ".lambda-and-fun/state.json"
+
+// State records the identifiers of every resource the helpers create, so later commands can look them up
+// by the name the user gave rather than asking for ARNs and IDs. Each map is keyed by that name
+type State struct {
+	Roles     map[string]*Role     `json:"roles"`
+	Functions map[string]*Function `json:"functions"`
+	Gateways  map[string]*Gateway  `json:"gateways"`
+
+	path string
+}
+
+// Role is a created IAM role and the ARNs of the policies attached to it
+type Role struct {
+	Arn      string   `json:"arn"`
+	Policies []string `json:"policies,omitempty"`
+}
+
+// Function is a created Lambda function, the statement IDs of the permissions added to it, the version each
+// of its aliases points at, the UUIDs of the event source mappings invoking it, the ARNs of the policies its
+// triggers attached to its role and the names of the rules invoking it on a schedule
+type Function struct {
+	Arn             string            `json:"arn"`
+	Role            string            `json:"role,omitempty"`
+	Permissions     []string          `json:"permissions,omitempty"`
+	Aliases         map[string]string `json:"aliases,omitempty"`
+	Triggers        []string          `json:"triggers,omitempty"`
+	TriggerPolicies []string          `json:"triggerPolicies,omitempty"`
+	Schedules       []string          `json:"schedules,omitempty"`
+}
+
+// Gateway is a created REST API and the resource, deployment and stage configured on it
+type Gateway struct {
+	RestAPIID      string `json:"restApiId"`
+	RootResourceID string `json:"rootResourceId,omitempty"`
+	ResourceID     string `json:"resourceId,omitempty"`
+	DeploymentID   string `json:"deploymentId,omitempty"`
+	Stage          string `json:"stage,omitempty"`
+	FunctionName   string `json:"functionName,omitempty"`
+}
+
+// Load reads the state file at path, a missing file is an empty state which is created on the first Save
+func Load(path string) (*State, error) {
+	s := &State{path: path}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Roles == nil {
+		s.Roles = map[string]*Role{}
+	}
+	if s.Functions == nil {
+		s.Functions = map[string]*Function{}
+	}
+	if s.Gateways == nil {
+		s.Gateways = map[string]*Gateway{}
+	}
+	return s, nil
+}
+
+// Path returns the file the state is saved to
+func (s *State) Path() string {
+	return s.path
+}
+
+// Save writes the state back to the file it was loaded from. It is written to a temporary file first and
+// renamed into place so an interrupted write never leaves a truncated state file behind
+func (s *State) Save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".state")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// GatewayByID returns the name and record of the gateway with the given REST API ID
+func (s *State) GatewayByID(id string) (string, *Gateway, bool) {
+	for name, g := range s.Gateways {
+		if g.RestAPIID == id {
+			return name, g, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,43 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".lambda-and-fun", "state.json")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) of a missing file failed: %v", path, err)
+	}
+	if len(s.Roles) != 0 || len(s.Functions) != 0 || len(s.Gateways) != 0 {
+		t.Errorf("Load(%q) of a missing file failed, expected an empty state, got %+v", path, s)
+	}
+
+	s.Roles["stack-role"] = &Role{Arn: "arn:aws:iam::123456789012:role/stack-role"}
+	s.Gateways["stack-api"] = &Gateway{RestAPIID: "a1b2c3", ResourceID: "d4e5f6"}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) failed: %v", path, err)
+	}
+	if got := s.Roles["stack-role"].Arn; got != "arn:aws:iam::123456789012:role/stack-role" {
+		t.Errorf("Load(%q) failed, expected the saved role ARN, got %v", path, got)
+	}
+	name, g, ok := s.GatewayByID("a1b2c3")
+	if !ok || name != "stack-api" || g.ResourceID != "d4e5f6" {
+		t.Errorf("GatewayByID(%q) failed, got %v %+v %v", "a1b2c3", name, g, ok)
+	}
+}
@@ -6,5 +6,5 @@ require (
 	github.com/aws/aws-sdk-go v1.34.0
 	github.com/spf13/cobra v1.0.0
 	golang.org/x/net v0.0.0-20200226121028-0de0cce0169b // indirect
-	gopkg.in/yaml.v2 v2.2.8 // indirect
+	gopkg.in/yaml.v2 v2.2.8
 )